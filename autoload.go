@@ -0,0 +1,389 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+const (
+	autoloadFileName  = ".envman"
+	allowListFileName = "allowed"
+
+	envAutoloadFile    = "ENVMAN_AUTOLOAD_FILE"
+	envAutoloadHash    = "ENVMAN_AUTOLOAD_HASH"
+	envAutoloadRestore = "ENVMAN_AUTOLOAD_RESTORE"
+)
+
+// autoloadDecl is a file that asks for profiles to be loaded on entering
+// its directory: either an .envman file listing profile names, or an
+// envman.toml with an autoload list.
+type autoloadDecl struct {
+	path     string
+	profiles []string
+}
+
+// previousValue remembers what a variable held before autoload replaced it.
+type previousValue struct {
+	Set   bool   `json:"set"`
+	Value string `json:"value,omitempty"`
+}
+
+// findAutoloadDecl looks upward from dir for the nearest .envman file or
+// envman.toml. It returns nil when there is none, or when the nearest
+// envman.toml does not declare any profiles.
+func findAutoloadDecl(dir string) (*autoloadDecl, error) {
+	for {
+		declPath := filepath.Join(dir, autoloadFileName)
+		if info, err := os.Stat(declPath); err == nil && info.Mode().IsRegular() {
+			profiles, err := readAutoloadFile(declPath)
+			if err != nil {
+				return nil, err
+			}
+			return &autoloadDecl{path: declPath, profiles: profiles}, nil
+		}
+
+		configPath := filepath.Join(dir, projectConfigFileName)
+		if info, err := os.Stat(configPath); err == nil && info.Mode().IsRegular() {
+			var cfg projectConfig
+			if _, err := toml.DecodeFile(configPath, &cfg); err != nil {
+				return nil, fmt.Errorf("failed to parse %s: %v", configPath, err)
+			}
+			if len(cfg.Autoload) == 0 {
+				return nil, nil
+			}
+			return &autoloadDecl{path: configPath, profiles: cfg.Autoload}, nil
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil, nil
+		}
+		dir = parent
+	}
+}
+
+// readAutoloadFile reads profile names separated by whitespace or newlines.
+// Lines starting with # are comments.
+func readAutoloadFile(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", path, err)
+	}
+	defer f.Close()
+
+	var profiles []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		profiles = append(profiles, strings.Fields(line)...)
+	}
+	return profiles, scanner.Err()
+}
+
+// hash covers the declaring file and every project profile it names, so a
+// git pull that changes either needs a new envman allow.
+func (d *autoloadDecl) hash() (string, error) {
+	h := sha256.New()
+	content, err := os.ReadFile(d.path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %v", d.path, err)
+	}
+	h.Write(content)
+	for _, name := range d.profiles {
+		if !isProjectProfileName(name) {
+			continue
+		}
+		loc, err := findProjectProfile(name)
+		if err != nil {
+			return "", err
+		}
+		content, err := os.ReadFile(loc.path)
+		if err != nil {
+			return "", fmt.Errorf("failed to read %s: %v", loc.path, err)
+		}
+		h.Write([]byte("\x00" + name + "\x00"))
+		h.Write(content)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func allowListPath() (string, error) {
+	dir, err := dataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, allowListFileName), nil
+}
+
+// readAllowList returns the trusted hash for each declaring file path. The
+// file holds one "<sha256>  <path>" line per entry.
+func readAllowList() (map[string]string, error) {
+	allowed := make(map[string]string)
+	path, err := allowListPath()
+	if err != nil {
+		return nil, err
+	}
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return allowed, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read allow list: %v", err)
+	}
+	for _, line := range strings.Split(string(content), "\n") {
+		parts := strings.SplitN(line, "  ", 2)
+		if len(parts) == 2 {
+			allowed[parts[1]] = parts[0]
+		}
+	}
+	return allowed, nil
+}
+
+func writeAllowList(allowed map[string]string) error {
+	path, err := allowListPath()
+	if err != nil {
+		return err
+	}
+	paths := make([]string, 0, len(allowed))
+	for p := range allowed {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	var sb strings.Builder
+	for _, p := range paths {
+		sb.WriteString(allowed[p] + "  " + p + "\n")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), profileDirMode); err != nil {
+		return fmt.Errorf("failed to create %s: %v", filepath.Dir(path), err)
+	}
+	if err := writeFileAtomic(path, []byte(sb.String()), profileFileMode); err != nil {
+		return fmt.Errorf("failed to write allow list: %v", err)
+	}
+	// Lists written by older versions were world-readable.
+	return os.Chmod(path, profileFileMode)
+}
+
+func autoloadDeclFor(dir string) (*autoloadDecl, error) {
+	if dir == "" {
+		cwd, err := os.Getwd()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get working directory: %v", err)
+		}
+		dir = cwd
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	decl, err := findAutoloadDecl(abs)
+	if err != nil {
+		return nil, err
+	}
+	if decl == nil {
+		return nil, fmt.Errorf("no %s file or %s with autoload found from %s", autoloadFileName, projectConfigFileName, abs)
+	}
+	return decl, nil
+}
+
+// AllowAutoload trusts the declaration found from dir in its current state.
+func AllowAutoload(dir string) error {
+	decl, err := autoloadDeclFor(dir)
+	if err != nil {
+		return err
+	}
+	hash, err := decl.hash()
+	if err != nil {
+		return err
+	}
+	allowed, err := readAllowList()
+	if err != nil {
+		return err
+	}
+	allowed[decl.path] = hash
+	if err := writeAllowList(allowed); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "%s%s%s Allowed%s %s (profiles: %s)\n",
+		colorGreen,
+		colorBold,
+		iconCheck,
+		colorReset,
+		decl.path,
+		strings.Join(decl.profiles, ", "),
+	)
+	return nil
+}
+
+// DenyAutoload removes the declaration found from dir from the allow list.
+func DenyAutoload(dir string) error {
+	decl, err := autoloadDeclFor(dir)
+	if err != nil {
+		return err
+	}
+	allowed, err := readAllowList()
+	if err != nil {
+		return err
+	}
+	delete(allowed, decl.path)
+	if err := writeAllowList(allowed); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "%s%s%s Denied%s %s\n",
+		colorYellow,
+		colorBold,
+		iconInfo,
+		colorReset,
+		decl.path,
+	)
+	return nil
+}
+
+// AutoloadHook prints shell code that brings the environment in line with
+// the working directory: it unloads what a previous directory loaded and
+// loads the profiles declared for the current one if they are trusted.
+// Messages go to stderr so stdout can be evaluated.
+func AutoloadHook(shell string) error {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	decl, err := findAutoloadDecl(cwd)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "envman: %v\n", err)
+		decl = nil
+	}
+
+	var hash string
+	if decl != nil {
+		if hash, err = decl.hash(); err != nil {
+			fmt.Fprintf(os.Stderr, "envman: %v\n", err)
+			decl = nil
+		}
+	}
+
+	loadedFile := os.Getenv(envAutoloadFile)
+	if decl != nil && loadedFile == decl.path && os.Getenv(envAutoloadHash) == hash {
+		return nil
+	}
+
+	var out strings.Builder
+	env := currentEnv()
+	if loadedFile != "" {
+		restore := decodeRestore(os.Getenv(envAutoloadRestore))
+		keys := make([]string, 0, len(restore))
+		for key := range restore {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			prev := restore[key]
+			if prev.Set {
+				out.WriteString(formatSet(key, prev.Value, shell))
+				env[key] = prev.Value
+			} else {
+				out.WriteString(formatUnset(key, shell))
+				delete(env, key)
+			}
+		}
+		for _, key := range []string{envAutoloadFile, envAutoloadHash, envAutoloadRestore} {
+			out.WriteString(formatUnset(key, shell))
+		}
+		fmt.Fprintf(os.Stderr, "envman: unloaded %s\n", displayPath(loadedFile))
+	}
+
+	if decl != nil {
+		allowed, err := readAllowList()
+		if err != nil {
+			return err
+		}
+		if allowed[decl.path] != hash {
+			fmt.Fprintf(os.Stderr, "envman: %s is not allowed. Review it, then run 'envman allow' to load %s\n",
+				displayPath(decl.path),
+				strings.Join(decl.profiles, ", "),
+			)
+		} else if protected, err := protectedProfiles(decl.profiles); err != nil || len(protected) > 0 {
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "envman: %v\n", err)
+			} else {
+				// The hook has no terminal to confirm on, so protected
+				// profiles are only ever loaded explicitly.
+				fmt.Fprintf(os.Stderr, "envman: not loading %s from %s (protected: %s). Run 'envman load %s' to confirm and load it\n",
+					strings.Join(decl.profiles, ", "),
+					displayPath(decl.path),
+					strings.Join(protected, ", "),
+					strings.Join(decl.profiles, " "),
+				)
+			}
+		} else {
+			vars, _, err := mergeProfiles(decl.profiles)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "envman: %v\n", err)
+			} else {
+				restore := make(map[string]previousValue)
+				for _, v := range vars {
+					if _, done := restore[v.Key]; !done {
+						value, set := env[v.Key]
+						restore[v.Key] = previousValue{Set: set, Value: value}
+					}
+				}
+				out.WriteString(formatExports(vars, shell))
+				out.WriteString(formatSet(envAutoloadFile, decl.path, shell))
+				out.WriteString(formatSet(envAutoloadHash, hash, shell))
+				out.WriteString(formatSet(envAutoloadRestore, encodeRestore(restore), shell))
+				fmt.Fprintf(os.Stderr, "envman: loaded %s (from %s)\n", strings.Join(decl.profiles, ", "), displayPath(decl.path))
+			}
+		}
+	}
+
+	fmt.Print(out.String())
+	return nil
+}
+
+func currentEnv() map[string]string {
+	env := make(map[string]string)
+	for _, kv := range os.Environ() {
+		if parts := strings.SplitN(kv, "=", 2); len(parts) == 2 {
+			env[parts[0]] = parts[1]
+		}
+	}
+	return env
+}
+
+func encodeRestore(restore map[string]previousValue) string {
+	data, _ := json.Marshal(restore)
+	return base64.StdEncoding.EncodeToString(data)
+}
+
+func decodeRestore(encoded string) map[string]previousValue {
+	restore := make(map[string]previousValue)
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return restore
+	}
+	json.Unmarshal(data, &restore)
+	return restore
+}
+
+func formatSet(key, value, shell string) string {
+	return formatExports([]profileVariable{{Key: key, Value: value}}, shell)
+}
+
+func formatUnset(key, shell string) string {
+	if shell == "fish" {
+		return fmt.Sprintf("set -e %s\n", key)
+	}
+	return fmt.Sprintf("unset %s\n", key)
+}
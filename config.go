@@ -0,0 +1,636 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// Config is the structured envman configuration stored as TOML.
+type Config struct {
+	ProfileDirs        []string            `toml:"profile_dirs"`
+	WriteDir           string              `toml:"write_dir"`
+	Editor             string              `toml:"editor"`
+	Theme              string              `toml:"theme"`
+	MaskPatterns       []string            `toml:"mask_patterns"`
+	BackupRetention    int                 `toml:"backup_retention"`
+	Shell              string              `toml:"shell"`
+	ProtectedProfiles  []string            `toml:"protected_profiles"`
+	ProtectedConfirm   string              `toml:"protected_confirm"`
+	ReadOnlyProfiles   []string            `toml:"readonly_profiles"`
+	ReviewProfiles     []string            `toml:"review_profiles"`
+	SkipReviewProfiles []string            `toml:"skip_review_profiles"`
+	Autoload           bool                `toml:"autoload"`
+	ReloadHint         bool                `toml:"reload_hint"`
+	LockTimeout        int                 `toml:"lock_timeout"`
+	Stacks             map[string][]string `toml:"stacks"`
+}
+
+var (
+	configThemes = []string{"default", "mono"}
+	configShells = []string{"", "bash", "zsh", "fish"}
+)
+
+const configHeader = `# envman configuration
+# Manage with: envman config get|set|list|edit|path
+
+`
+
+// configKey describes one setting for envman config get/set.
+type configKey struct {
+	name        string
+	description string
+	get         func(c *Config) []string
+	set         func(c *Config, values []string) error
+}
+
+var configKeys = []configKey{
+	{
+		name:        "profile_dirs",
+		description: "Profile search path, first match wins",
+		get:         func(c *Config) []string { return c.ProfileDirs },
+		set: func(c *Config, values []string) error {
+			c.ProfileDirs = values
+			return nil
+		},
+	},
+	{
+		name:        "write_dir",
+		description: "Directory new profiles are written to (defaults to the first profile_dirs entry)",
+		get:         func(c *Config) []string { return []string{c.WriteDir} },
+		set: func(c *Config, values []string) error {
+			c.WriteDir = strings.Join(values, " ")
+			return nil
+		},
+	},
+	{
+		name:        "editor",
+		description: "External editor command (falls back to $EDITOR, then nano)",
+		get:         func(c *Config) []string { return []string{c.Editor} },
+		set: func(c *Config, values []string) error {
+			c.Editor = strings.Join(values, " ")
+			return nil
+		},
+	},
+	{
+		name:        "theme",
+		description: "Color theme: " + strings.Join(configThemes, ", "),
+		get:         func(c *Config) []string { return []string{c.Theme} },
+		set: func(c *Config, values []string) error {
+			c.Theme = strings.Join(values, " ")
+			return nil
+		},
+	},
+	{
+		name:        "mask_patterns",
+		description: "Key patterns whose values are masked in previews",
+		get:         func(c *Config) []string { return c.MaskPatterns },
+		set: func(c *Config, values []string) error {
+			c.MaskPatterns = values
+			return nil
+		},
+	},
+	{
+		name:        "backup_retention",
+		description: "Number of backups kept per profile (0 disables backups)",
+		get:         func(c *Config) []string { return []string{strconv.Itoa(c.BackupRetention)} },
+		set: func(c *Config, values []string) error {
+			if len(values) != 1 {
+				return fmt.Errorf("backup_retention takes a single number")
+			}
+			n, err := strconv.Atoi(values[0])
+			if err != nil {
+				return fmt.Errorf("backup_retention must be a number: %v", err)
+			}
+			c.BackupRetention = n
+			return nil
+		},
+	},
+	{
+		name:        "shell",
+		description: "Default shell (bash, zsh, fish; empty to detect from $SHELL)",
+		get:         func(c *Config) []string { return []string{c.Shell} },
+		set: func(c *Config, values []string) error {
+			c.Shell = strings.Join(values, " ")
+			return nil
+		},
+	},
+	{
+		name:        "protected_profiles",
+		description: "Profiles that need confirmation before use",
+		get:         func(c *Config) []string { return c.ProtectedProfiles },
+		set: func(c *Config, values []string) error {
+			c.ProtectedProfiles = values
+			return nil
+		},
+	},
+	{
+		name:        "protected_confirm",
+		description: "How protected profiles are confirmed: " + strings.Join(configConfirmModes, ", "),
+		get:         func(c *Config) []string { return []string{c.ProtectedConfirm} },
+		set: func(c *Config, values []string) error {
+			c.ProtectedConfirm = strings.Join(values, " ")
+			return nil
+		},
+	},
+	{
+		name:        "readonly_profiles",
+		description: "Profiles that set, unset, import, delete and the editor will not change",
+		get:         func(c *Config) []string { return c.ReadOnlyProfiles },
+		set: func(c *Config, values []string) error {
+			c.ReadOnlyProfiles = values
+			return nil
+		},
+	},
+	{
+		name:        "review_profiles",
+		description: "Profiles whose changes the editor shows for confirmation before saving",
+		get:         func(c *Config) []string { return c.ReviewProfiles },
+		set: func(c *Config, values []string) error {
+			c.ReviewProfiles = values
+			return nil
+		},
+	},
+	{
+		name:        "skip_review_profiles",
+		description: "Protected profiles the editor saves without showing the changes first",
+		get:         func(c *Config) []string { return c.SkipReviewProfiles },
+		set: func(c *Config, values []string) error {
+			c.SkipReviewProfiles = values
+			return nil
+		},
+	},
+	{
+		name:        "autoload",
+		description: "Load allowed .envman declarations on directory change",
+		get:         func(c *Config) []string { return []string{strconv.FormatBool(c.Autoload)} },
+		set: func(c *Config, values []string) error {
+			if len(values) != 1 {
+				return fmt.Errorf("autoload takes true or false")
+			}
+			b, err := strconv.ParseBool(values[0])
+			if err != nil {
+				return fmt.Errorf("autoload must be true or false")
+			}
+			c.Autoload = b
+			return nil
+		},
+	},
+	{
+		name:        "reload_hint",
+		description: "Remind before each prompt when loaded profiles changed on disk",
+		get:         func(c *Config) []string { return []string{strconv.FormatBool(c.ReloadHint)} },
+		set: func(c *Config, values []string) error {
+			if len(values) != 1 {
+				return fmt.Errorf("reload_hint takes true or false")
+			}
+			b, err := strconv.ParseBool(values[0])
+			if err != nil {
+				return fmt.Errorf("reload_hint must be true or false")
+			}
+			c.ReloadHint = b
+			return nil
+		},
+	},
+	{
+		name:        "lock_timeout",
+		description: "Seconds to wait for another envman process to finish writing a profile",
+		get:         func(c *Config) []string { return []string{strconv.Itoa(c.LockTimeout)} },
+		set: func(c *Config, values []string) error {
+			if len(values) != 1 {
+				return fmt.Errorf("lock_timeout takes a single number")
+			}
+			n, err := strconv.Atoi(values[0])
+			if err != nil {
+				return fmt.Errorf("lock_timeout must be a number: %v", err)
+			}
+			c.LockTimeout = n
+			return nil
+		},
+	},
+}
+
+func findConfigKey(name string) (configKey, error) {
+	for _, k := range configKeys {
+		if k.name == name {
+			return k, nil
+		}
+	}
+	var names []string
+	for _, k := range configKeys {
+		names = append(names, k.name)
+	}
+	return configKey{}, fmt.Errorf("unknown config key '%s' (expected one of: %s)", name, strings.Join(names, ", "))
+}
+
+func defaultConfig() (*Config, error) {
+	dir, err := dataDir()
+	if err != nil {
+		return nil, err
+	}
+	return &Config{
+		ProfileDirs: []string{dir},
+		Theme:       "default",
+		MaskPatterns: []string{
+			"*KEY*",
+			"*SECRET*",
+			"*TOKEN*",
+			"*PASSWORD*",
+			"*PASSWD*",
+			"*CREDENTIAL*",
+			"*PRIVATE*",
+		},
+		BackupRetention:    1,
+		ProtectedProfiles:  []string{},
+		ProtectedConfirm:   "yes",
+		ReadOnlyProfiles:   []string{},
+		ReviewProfiles:     []string{},
+		SkipReviewProfiles: []string{},
+		LockTimeout:        10,
+		Stacks:             map[string][]string{},
+	}, nil
+}
+
+func (c *Config) validate() error {
+	if len(c.ProfileDirs) == 0 {
+		return fmt.Errorf("profile_dirs must list at least one directory")
+	}
+	for _, dir := range c.ProfileDirs {
+		if strings.TrimSpace(dir) == "" {
+			return fmt.Errorf("profile_dirs cannot contain an empty path")
+		}
+	}
+	if strings.TrimSpace(c.WriteDir) != "" {
+		writeDir, err := writeProfileDir(c)
+		if err != nil {
+			return err
+		}
+		dirs, err := profileSearchPath(c)
+		if err != nil {
+			return err
+		}
+		if !containsString(dirs, writeDir) {
+			return fmt.Errorf("write_dir %s must also be listed in profile_dirs", c.WriteDir)
+		}
+	}
+	if !containsString(configThemes, c.Theme) {
+		return fmt.Errorf("invalid theme '%s' (expected one of: %s)", c.Theme, strings.Join(configThemes, ", "))
+	}
+	for _, pattern := range c.MaskPatterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid mask pattern '%s': %v", pattern, err)
+		}
+	}
+	if c.BackupRetention < 0 {
+		return fmt.Errorf("backup_retention cannot be negative")
+	}
+	if c.LockTimeout < 1 {
+		return fmt.Errorf("lock_timeout must be at least 1 second")
+	}
+	if !containsString(configShells, c.Shell) {
+		return fmt.Errorf("invalid shell '%s' (expected bash, zsh or fish)", c.Shell)
+	}
+	for _, name := range c.ProtectedProfiles {
+		if strings.TrimSpace(name) == "" || strings.Contains(name, "/") {
+			return fmt.Errorf("invalid protected profile name '%s'", name)
+		}
+	}
+	if !containsString(configConfirmModes, c.ProtectedConfirm) {
+		return fmt.Errorf("invalid protected_confirm '%s' (expected one of: %s)", c.ProtectedConfirm, strings.Join(configConfirmModes, ", "))
+	}
+	for _, name := range c.ReadOnlyProfiles {
+		if strings.TrimSpace(name) == "" || strings.Contains(name, "/") {
+			return fmt.Errorf("invalid read-only profile name '%s'", name)
+		}
+	}
+	for _, names := range [][]string{c.ReviewProfiles, c.SkipReviewProfiles} {
+		for _, name := range names {
+			if strings.TrimSpace(name) == "" || strings.Contains(name, "/") {
+				return fmt.Errorf("invalid review profile name '%s'", name)
+			}
+		}
+	}
+	for name, members := range c.Stacks {
+		if err := validateStack(c, name, members); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// parseConfig decodes TOML config content on top of the defaults, so keys
+// missing from the file keep their default values.
+func parseConfig(content []byte) (*Config, error) {
+	cfg, err := defaultConfig()
+	if err != nil {
+		return nil, err
+	}
+	meta, err := toml.Decode(string(content), cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config: %v", err)
+	}
+	if undecoded := meta.Undecoded(); len(undecoded) > 0 {
+		return nil, fmt.Errorf("unknown config key '%s'", undecoded[0])
+	}
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func loadConfig() (*Config, error) {
+	configFile, err := configFilePath()
+	if err != nil {
+		return nil, err
+	}
+	content, err := os.ReadFile(configFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %v", err)
+	}
+	cfg, err := parseConfig(content)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", configFile, err)
+	}
+	return cfg, nil
+}
+
+func encodeConfig(cfg *Config) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(configHeader)
+	if err := toml.NewEncoder(&buf).Encode(cfg); err != nil {
+		return nil, fmt.Errorf("failed to encode config: %v", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// saveConfig replaces the config file atomically. A symlinked config, as
+// kept in a dotfiles repository, is written through to its target.
+func saveConfig(configFile string, cfg *Config) error {
+	if err := cfg.validate(); err != nil {
+		return err
+	}
+	content, err := encodeConfig(cfg)
+	if err != nil {
+		return err
+	}
+	return writeConfigFile(configFile, content)
+}
+
+func writeConfigFile(configFile string, content []byte) error {
+	if target, err := filepath.EvalSymlinks(configFile); err == nil {
+		configFile = target
+	}
+	if err := writeFileAtomic(configFile, content, 0644); err != nil {
+		return fmt.Errorf("failed to write config file: %v", err)
+	}
+	return nil
+}
+
+// updateConfig applies change to the config while holding its lock, so
+// concurrent commands cannot overwrite each other's settings. The config
+// is only written when change reports that it changed something.
+func updateConfig(change func(cfg *Config) (bool, error)) error {
+	configFile, err := configFilePath()
+	if err != nil {
+		return err
+	}
+	lock, err := lockConfig(configFile)
+	if err != nil {
+		return err
+	}
+	defer lock.release()
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	changed, err := change(cfg)
+	if err != nil || !changed {
+		return err
+	}
+	return saveConfig(configFile, cfg)
+}
+
+// isLegacyConfig reports whether content is the single-line PROFILE_DIR=
+// format written by envman v0.1.0.
+func isLegacyConfig(content []byte) bool {
+	for _, line := range strings.Split(string(content), "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "PROFILE_DIR=") {
+			return true
+		}
+	}
+	return false
+}
+
+// configFromLegacy converts the old PROFILE_DIR= format. The old template
+// baked in /home/<user>/.envman/, so a profile directory that does not
+// exist under /home is replaced with the resolved data directory.
+func configFromLegacy(content []byte) (*Config, error) {
+	cfg, err := defaultConfig()
+	if err != nil {
+		return nil, err
+	}
+	for _, line := range strings.Split(string(content), "\n") {
+		if !strings.HasPrefix(line, "PROFILE_DIR=") {
+			continue
+		}
+		profileDir := strings.TrimPrefix(line, "PROFILE_DIR=")
+		profileDir = strings.Split(profileDir, "#")[0]
+		profileDir = strings.TrimSpace(profileDir)
+		if profileDir == "" {
+			break
+		}
+		if strings.HasPrefix(profileDir, "/home/") {
+			if _, err := os.Stat(profileDir); os.IsNotExist(err) {
+				break
+			}
+		}
+		cfg.ProfileDirs = []string{filepath.Clean(profileDir)}
+		break
+	}
+	return cfg, nil
+}
+
+// migrateLegacyConfigFile rewrites an old-format config file in place as
+// TOML, keeping the original next to it with a .old suffix.
+func migrateLegacyConfigFile(configFile string, content []byte) error {
+	cfg, err := configFromLegacy(content)
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(configFile+".old", content, 0644); err != nil {
+		return fmt.Errorf("failed to back up old config: %v", err)
+	}
+	return saveConfig(configFile, cfg)
+}
+
+func EnsureConfig() error {
+	configFile, err := configFilePath()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(configFile), 0755); err != nil {
+		return fmt.Errorf("failed to create config directory: %v", err)
+	}
+
+	content, err := os.ReadFile(configFile)
+	if os.IsNotExist(err) {
+		for _, legacy := range legacyConfigFilePaths(configFile) {
+			legacyContent, err := os.ReadFile(legacy)
+			if err != nil || !isLegacyConfig(legacyContent) {
+				continue
+			}
+			cfg, err := configFromLegacy(legacyContent)
+			if err != nil {
+				return err
+			}
+			return saveConfig(configFile, cfg)
+		}
+		cfg, err := defaultConfig()
+		if err != nil {
+			return err
+		}
+		return saveConfig(configFile, cfg)
+	} else if err != nil {
+		return fmt.Errorf("failed to read config file: %v", err)
+	}
+
+	if isLegacyConfig(content) {
+		return migrateLegacyConfigFile(configFile, content)
+	}
+	return nil
+}
+
+// externalEditor returns the editor command from the config, then $EDITOR,
+// then nano.
+func externalEditor() []string {
+	if cfg, err := loadConfig(); err == nil && strings.TrimSpace(cfg.Editor) != "" {
+		return strings.Fields(cfg.Editor)
+	}
+	if editor := os.Getenv("EDITOR"); strings.TrimSpace(editor) != "" {
+		return strings.Fields(editor)
+	}
+	return []string{"nano"}
+}
+
+// isMaskedKey reports whether key matches one of the mask patterns. Matching
+// ignores case.
+func isMaskedKey(patterns []string, key string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(strings.ToUpper(pattern), strings.ToUpper(key)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func isProtectedProfile(cfg *Config, name string) bool {
+	return containsString(cfg.ProtectedProfiles, name)
+}
+
+// reviewBeforeSave reports whether the editor shows the changes to a
+// profile for confirmation before saving: for profiles in review_profiles,
+// and for protected profiles unless they are in skip_review_profiles.
+func reviewBeforeSave(cfg *Config, name string) bool {
+	if containsString(cfg.ReviewProfiles, name) {
+		return true
+	}
+	return isProtectedProfile(cfg, name) && !containsString(cfg.SkipReviewProfiles, name)
+}
+
+func isReadOnlyProfile(cfg *Config, name string) bool {
+	return containsString(cfg.ReadOnlyProfiles, name)
+}
+
+// updateProfileList adds name to or removes it from the list setting
+// keyName and saves the config. It reports whether anything changed.
+func updateProfileList(keyName, name string, add bool) (bool, error) {
+	k, err := findConfigKey(keyName)
+	if err != nil {
+		return false, err
+	}
+
+	changed := false
+	err = updateConfig(func(cfg *Config) (bool, error) {
+		current := k.get(cfg)
+		if containsString(current, name) == add {
+			return false, nil
+		}
+		updated := []string{}
+		for _, v := range current {
+			if v != name {
+				updated = append(updated, v)
+			}
+		}
+		if add {
+			updated = append(updated, name)
+		}
+		changed = true
+		return true, k.set(cfg, updated)
+	})
+	return changed, err
+}
+
+// profileListKeys are the list settings whose entries are profile names.
+var profileListKeys = []string{"protected_profiles", "readonly_profiles", "review_profiles", "skip_review_profiles"}
+
+// renameProfileLists replaces source with target in every list setting
+// that names profiles, so a renamed profile keeps its protection.
+func renameProfileLists(source, target string) error {
+	return updateConfig(func(cfg *Config) (bool, error) {
+		changed := false
+		for _, keyName := range profileListKeys {
+			k, err := findConfigKey(keyName)
+			if err != nil {
+				return false, err
+			}
+			current := k.get(cfg)
+			if !containsString(current, source) {
+				continue
+			}
+			updated := []string{}
+			for _, v := range current {
+				if v == source {
+					v = target
+				} else if v == target {
+					continue
+				}
+				updated = append(updated, v)
+			}
+			if err := k.set(cfg, updated); err != nil {
+				return false, err
+			}
+			changed = true
+		}
+		return changed, nil
+	})
+}
+
+// applyTheme switches the ANSI colors used for terminal output. The mono
+// theme disables them.
+func applyTheme(theme string) {
+	if theme != "mono" {
+		return
+	}
+	colorRed = ""
+	colorGreen = ""
+	colorYellow = ""
+	colorBold = ""
+	colorReset = ""
+}
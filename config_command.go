@@ -0,0 +1,229 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const configUsage = `Usage:
+  envman config path                 Print the config file location
+  envman config list                 Show all settings
+  envman config get <key>            Print one setting (lists one item per line)
+  envman config set <key> <value...> Change a setting (lists take several values
+                                     or one comma-separated value)
+  envman config edit                 Edit the config file and validate it`
+
+func ConfigCommand(args []string) error {
+	if len(args) == 0 {
+		fmt.Println(configUsage)
+		return nil
+	}
+
+	configFile, err := configFilePath()
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "path":
+		fmt.Println(configFile)
+		return nil
+	case "list":
+		cfg, err := loadConfig()
+		if err != nil {
+			return err
+		}
+		for _, k := range configKeys {
+			fmt.Printf("%s%s%s = %s  %s# %s%s\n",
+				colorBold,
+				k.name,
+				colorReset,
+				formatConfigValue(k, cfg),
+				colorYellow,
+				k.description,
+				colorReset,
+			)
+		}
+		return nil
+	case "get":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: envman config get <key>")
+		}
+		k, err := findConfigKey(args[1])
+		if err != nil {
+			return err
+		}
+		cfg, err := loadConfig()
+		if err != nil {
+			return err
+		}
+		for _, v := range k.get(cfg) {
+			fmt.Println(v)
+		}
+		return nil
+	case "set":
+		if len(args) < 2 {
+			return fmt.Errorf("usage: envman config set <key> <value...>")
+		}
+		k, err := findConfigKey(args[1])
+		if err != nil {
+			return err
+		}
+		values := args[2:]
+		if len(values) == 1 && isListConfigKey(k) {
+			values = splitConfigList(values[0])
+		}
+		var cfg *Config
+		err = updateConfig(func(current *Config) (bool, error) {
+			cfg = current
+			return true, k.set(cfg, values)
+		})
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s%s%s Set%s %s = %s\n",
+			colorGreen,
+			colorBold,
+			iconCheck,
+			colorReset,
+			k.name,
+			formatConfigValue(k, cfg),
+		)
+		return nil
+	case "edit":
+		return editConfig(configFile)
+	}
+	return fmt.Errorf("unknown config command '%s'\n%s", args[0], configUsage)
+}
+
+func isListConfigKey(k configKey) bool {
+	switch k.name {
+	case "profile_dirs", "mask_patterns", "protected_profiles", "readonly_profiles", "review_profiles", "skip_review_profiles":
+		return true
+	}
+	return false
+}
+
+func splitConfigList(value string) []string {
+	values := []string{}
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+func formatConfigValue(k configKey, cfg *Config) string {
+	values := k.get(cfg)
+	if !isListConfigKey(k) {
+		if k.name == "backup_retention" || k.name == "lock_timeout" || k.name == "autoload" || k.name == "reload_hint" {
+			return values[0]
+		}
+		return strconv.Quote(values[0])
+	}
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		quoted[i] = strconv.Quote(v)
+	}
+	return "[" + strings.Join(quoted, ", ") + "]"
+}
+
+// replaceEditedConfig writes edited over the config under its lock. If
+// another command changed the config since original was read, it asks
+// before overwriting that change.
+func replaceEditedConfig(configFile string, original, edited []byte, reader *bufio.Reader) error {
+	lock, err := lockConfig(configFile)
+	if err != nil {
+		return err
+	}
+	defer lock.release()
+
+	current, err := os.ReadFile(configFile)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %v", err)
+	}
+	if !bytes.Equal(current, original) {
+		fmt.Fprintf(os.Stderr, "%s%s%s The config changed while you were editing it.%s Overwrite those changes? [y/N]: ",
+			colorYellow,
+			colorBold,
+			iconWarning,
+			colorReset,
+		)
+		answer, err := reader.ReadString('\n')
+		if answer = strings.ToLower(strings.TrimSpace(answer)); err != nil || (answer != "y" && answer != "yes") {
+			return fmt.Errorf("config left unchanged")
+		}
+	}
+	return writeConfigFile(configFile, edited)
+}
+
+// editConfig opens a copy of the config in the external editor and only
+// replaces the real file once the edited copy validates.
+func editConfig(configFile string) error {
+	original, err := os.ReadFile(configFile)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %v", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(configFile), ".config-*.toml")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %v", err)
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath)
+	if _, err := tmp.Write(original); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write temporary file: %v", err)
+	}
+	tmp.Close()
+
+	reader := bufio.NewReader(os.Stdin)
+	for {
+		editor := externalEditor()
+		cmd := exec.Command(editor[0], append(editor[1:], tmpPath)...)
+		cmd.Stdin = os.Stdin
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("failed to open editor: %v", err)
+		}
+
+		edited, err := os.ReadFile(tmpPath)
+		if err != nil {
+			return fmt.Errorf("failed to read edited config: %v", err)
+		}
+		if _, err := parseConfig(edited); err != nil {
+			fmt.Fprintf(os.Stderr, "%s%s%sInvalid config:%s %v\nEdit again? [Y/n]: ",
+				colorRed,
+				colorBold,
+				iconX,
+				colorReset,
+				err,
+			)
+			answer, err := reader.ReadString('\n')
+			if answer = strings.ToLower(strings.TrimSpace(answer)); err != nil || answer == "n" || answer == "no" {
+				return fmt.Errorf("config left unchanged")
+			}
+			continue
+		}
+
+		if err := replaceEditedConfig(configFile, original, edited, reader); err != nil {
+			return err
+		}
+		fmt.Printf("%s%s%s Config saved:%s %s\n",
+			colorGreen,
+			colorBold,
+			iconCheck,
+			colorReset,
+			configFile,
+		)
+		return nil
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// testHome points $HOME, the config file and the data directory at a fresh
+// temporary directory, writes the default config and returns the profile
+// directory.
+func testHome(t *testing.T) string {
+	t.Helper()
+	home := t.TempDir()
+	dir := filepath.Join(home, "profiles")
+	t.Setenv("HOME", home)
+	t.Setenv("ENVMAN_CONFIG", filepath.Join(home, "config.toml"))
+	t.Setenv("ENVMAN_HOME", dir)
+	if err := EnsureConfig(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestEnsureConfigMigratesLegacyConfig(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("ENVMAN_HOME", t.TempDir())
+	profiles := t.TempDir()
+	legacy := []byte(fmt.Sprintf("PROFILE_DIR=%s # where profiles live\n", profiles))
+
+	t.Run("in place", func(t *testing.T) {
+		configFile := filepath.Join(t.TempDir(), "config.toml")
+		t.Setenv("ENVMAN_CONFIG", configFile)
+		if err := os.WriteFile(configFile, legacy, 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := EnsureConfig(); err != nil {
+			t.Fatal(err)
+		}
+		old, err := os.ReadFile(configFile + ".old")
+		if err != nil || string(old) != string(legacy) {
+			t.Errorf("original not kept: %q, %v", old, err)
+		}
+		if info, err := os.Stat(configFile); err != nil || info.Mode().Perm() != 0600 {
+			t.Errorf("config mode changed: %v, %v", info.Mode(), err)
+		}
+		cfg, err := loadConfig()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(cfg.ProfileDirs, []string{profiles}) {
+			t.Errorf("profile_dirs = %v, want [%s]", cfg.ProfileDirs, profiles)
+		}
+	})
+
+	t.Run("old file name", func(t *testing.T) {
+		configFile := filepath.Join(t.TempDir(), "config.toml")
+		t.Setenv("ENVMAN_CONFIG", configFile)
+		if err := os.WriteFile(filepath.Join(filepath.Dir(configFile), legacyConfigFileName), legacy, 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := EnsureConfig(); err != nil {
+			t.Fatal(err)
+		}
+		cfg, err := loadConfig()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(cfg.ProfileDirs, []string{profiles}) {
+			t.Errorf("profile_dirs = %v, want [%s]", cfg.ProfileDirs, profiles)
+		}
+	})
+}
+
+func TestUpdateConfigKeepsConcurrentChanges(t *testing.T) {
+	testHome(t)
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			if _, err := updateProfileList("review_profiles", name, true); err != nil {
+				t.Error(err)
+			}
+		}(fmt.Sprintf("p%d", i))
+	}
+	wg.Wait()
+
+	cfg, err := loadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.ReviewProfiles) != 20 {
+		t.Errorf("%d of 20 concurrent changes kept: %v", len(cfg.ReviewProfiles), cfg.ReviewProfiles)
+	}
+}
+
+func TestSaveConfigWritesThroughSymlink(t *testing.T) {
+	testHome(t)
+	configFile, err := configFilePath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	target := filepath.Join(t.TempDir(), "dotfiles.toml")
+	if err := os.Rename(configFile, target); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(target, configFile); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := updateProfileList("review_profiles", "a", true); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Lstat(configFile); err != nil || info.Mode()&os.ModeSymlink == 0 {
+		t.Fatalf("config is no longer a symlink: %v", err)
+	}
+	cfg, err := loadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(cfg.ReviewProfiles, []string{"a"}) {
+		t.Errorf("review_profiles = %v", cfg.ReviewProfiles)
+	}
+}
@@ -0,0 +1,602 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/atotto/clipboard"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+type dashboardMode int
+
+const (
+	modeBrowse dashboardMode = iota
+	modePrompt
+	modeConfirmDelete
+	modeDiffPick
+	modeDiff
+)
+
+const dashboardListWidth = 30
+
+var (
+	paneStyle = lipgloss.NewStyle().
+			Border(lipgloss.RoundedBorder()).
+			BorderForeground(lipgloss.Color("3")).
+			Padding(0, 1)
+	activePaneStyle = paneStyle.BorderForeground(lipgloss.Color("2"))
+)
+
+// editorExec runs the tview editor through tea.Exec so bubbletea releases
+// the terminal while it is open.
+type editorExec struct {
+	name string
+}
+
+func (c *editorExec) Run() error {
+	return EditProfile(c.name, false)
+}
+
+func (c *editorExec) SetStdin(io.Reader)  {}
+func (c *editorExec) SetStdout(io.Writer) {}
+func (c *editorExec) SetStderr(io.Writer) {}
+
+type editorFinishedMsg struct {
+	name string
+	err  error
+}
+
+func NewAppModel() AppModel {
+	m := AppModel{width: 80, height: 24}
+	if cfg, err := loadConfig(); err == nil {
+		m.masks = cfg.MaskPatterns
+	}
+	m.reload("")
+	return m
+}
+
+// reload re-reads the profile list and keeps the cursor on selectName when
+// it is still present.
+func (m *AppModel) reload(selectName string) {
+	profiles, err := collectProfiles()
+	if err != nil {
+		m.err = err
+		return
+	}
+	m.profiles = activeProfiles(profiles)
+	if selectName != "" {
+		for i, p := range m.profiles {
+			if strings.TrimSuffix(p.name, ".env") == selectName {
+				m.cursor = i
+				break
+			}
+		}
+	}
+	if m.cursor >= len(m.profiles) {
+		m.cursor = len(m.profiles) - 1
+	}
+	if m.cursor < 0 {
+		m.cursor = 0
+	}
+	m.loadPreview()
+}
+
+func (m *AppModel) loadPreview() {
+	m.preview = nil
+	if name := m.selected(); name != "" {
+		if vars, err := loadProfileVariables(name); err == nil {
+			m.preview = vars
+		}
+	}
+}
+
+func (m AppModel) selected() string {
+	if len(m.profiles) == 0 {
+		return ""
+	}
+	return strings.TrimSuffix(m.profiles[m.cursor].name, ".env")
+}
+
+func (m AppModel) selectedProtected() bool {
+	return len(m.profiles) > 0 && containsString(m.profiles[m.cursor].flags, "protected")
+}
+
+// protected reports whether the profile a name resolves to is protected.
+func (m AppModel) protected(name string) bool {
+	for _, p := range m.profiles {
+		if strings.TrimSuffix(p.name, ".env") == name && !containsString(p.flags, "shadowed") {
+			return containsString(p.flags, "protected")
+		}
+	}
+	return false
+}
+
+// revealed reports whether secrets are shown: the user asked to reveal
+// them and no profile on screen is protected.
+func (m AppModel) revealed() bool {
+	if m.mode == modeDiff {
+		return m.reveal && !m.protected(m.diffBase) && !m.protected(m.diffTarget)
+	}
+	return m.reveal && !m.selectedProtected()
+}
+
+func (m *AppModel) setStatus(format string, args ...interface{}) {
+	m.status = fmt.Sprintf(format, args...)
+	m.statusErr = false
+}
+
+func (m *AppModel) setError(err error) {
+	m.status = err.Error()
+	m.statusErr = true
+}
+
+func (m AppModel) Init() tea.Cmd {
+	return nil
+}
+
+func (m AppModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width = msg.Width
+		m.height = msg.Height
+		return m, nil
+	case editorFinishedMsg:
+		if msg.err != nil {
+			m.setError(msg.err)
+		} else {
+			m.setStatus("Closed editor for %s", msg.name)
+		}
+		m.reload(msg.name)
+		return m, nil
+	case tea.KeyMsg:
+		if msg.Type == tea.KeyCtrlC {
+			return m, tea.Quit
+		}
+		switch m.mode {
+		case modePrompt:
+			return m.updatePrompt(msg)
+		case modeConfirmDelete:
+			return m.updateConfirmDelete(msg)
+		case modeDiffPick:
+			return m.updateDiffPick(msg)
+		case modeDiff:
+			if msg.String() == "esc" || msg.String() == "q" {
+				m.mode = modeBrowse
+				m.diff = nil
+			}
+			return m, nil
+		}
+		return m.updateBrowse(msg)
+	}
+	return m, nil
+}
+
+func (m AppModel) updateBrowse(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	name := m.selected()
+	m.status = ""
+	switch msg.String() {
+	case "q", "esc":
+		return m, tea.Quit
+	case "up", "k":
+		if m.cursor > 0 {
+			m.cursor--
+			m.loadPreview()
+		}
+	case "down", "j":
+		if m.cursor < len(m.profiles)-1 {
+			m.cursor++
+			m.loadPreview()
+		}
+	case "m":
+		if name != "" && m.selectedProtected() {
+			m.setError(fmt.Errorf("%s is protected; its secrets stay masked here. View it with: envman profile view %s", name, name))
+		} else {
+			m.reveal = !m.reveal
+		}
+	case "n":
+		m.startPrompt("create", "New profile name: ", "")
+	case "e", "enter":
+		if name != "" && m.selectedProtected() {
+			m.setError(fmt.Errorf("%s is protected; edit it with: envman profile edit %s %s", name, name, flagAllowProtected))
+		} else if name != "" {
+			return m, tea.Exec(&editorExec{name: name}, func(err error) tea.Msg {
+				return editorFinishedMsg{name: name, err: err}
+			})
+		}
+	case "c":
+		if name != "" && m.selectedProtected() {
+			m.setError(fmt.Errorf("%s is protected; a copy would not be. Unprotect it first with: envman profile unprotect %s", name, name))
+		} else if name != "" {
+			m.startPrompt("duplicate", fmt.Sprintf("Duplicate %s as: ", name), name+"-copy")
+		}
+	case "r":
+		if name != "" && m.selectedProtected() {
+			m.setError(fmt.Errorf("%s is protected; unprotect it first with: envman profile unprotect %s", name, name))
+		} else if name != "" {
+			m.startPrompt("rename", fmt.Sprintf("Rename %s to: ", name), name)
+		}
+	case "d":
+		if name != "" && m.selectedProtected() {
+			m.setError(fmt.Errorf("%s is protected; delete it with: envman profile delete %s %s", name, name, flagAllowProtected))
+		} else if name != "" {
+			m.mode = modeConfirmDelete
+		}
+	case "x":
+		if name != "" && m.selectedProtected() {
+			m.setError(fmt.Errorf("%s is protected; export it with: envman export %s", name, name))
+		} else if name != "" {
+			exports := formatExports(m.preview, detectShell())
+			if err := clipboard.WriteAll(exports); err != nil {
+				if altErr := tryAlternativeClipboard(exports); altErr != nil {
+					m.setError(fmt.Errorf("failed to copy to clipboard: %v", err))
+					break
+				}
+			}
+			m.setStatus("Copied export statements for %s to the clipboard", name)
+		}
+	case "D":
+		if name != "" && len(m.profiles) > 1 {
+			m.diffBase = name
+			m.mode = modeDiffPick
+			m.setStatus("Diff %s against: choose a profile and press enter", name)
+		}
+	case "R":
+		m.reload(name)
+		m.setStatus("Reloaded profiles")
+	}
+	return m, nil
+}
+
+func (m *AppModel) startPrompt(action, label, initial string) {
+	m.mode = modePrompt
+	m.promptAction = action
+	m.promptLabel = label
+	m.input = initial
+	m.status = ""
+}
+
+func (m AppModel) updatePrompt(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.Type {
+	case tea.KeyEsc:
+		m.mode = modeBrowse
+		m.setStatus("Cancelled")
+	case tea.KeyEnter:
+		m.mode = modeBrowse
+		return m.finishPrompt()
+	case tea.KeyBackspace:
+		if runes := []rune(m.input); len(runes) > 0 {
+			m.input = string(runes[:len(runes)-1])
+		}
+	case tea.KeyCtrlU:
+		m.input = ""
+	case tea.KeyRunes:
+		m.input += string(msg.Runes)
+	}
+	return m, nil
+}
+
+func (m AppModel) finishPrompt() (tea.Model, tea.Cmd) {
+	target := strings.TrimSpace(m.input)
+	source := m.selected()
+
+	switch m.promptAction {
+	case "create":
+		if _, err := writeNewProfile(target, []byte("")); err != nil {
+			m.setError(err)
+			return m, nil
+		}
+		m.reload(target)
+		return m, tea.Exec(&editorExec{name: target}, func(err error) tea.Msg {
+			return editorFinishedMsg{name: target, err: err}
+		})
+	case "duplicate":
+		if err := duplicateProfile(source, target); err != nil {
+			m.setError(err)
+			return m, nil
+		}
+		m.reload(target)
+		m.setStatus("Duplicated %s as %s", source, target)
+	case "rename":
+		if target == source {
+			return m, nil
+		}
+		if err := renameProfile(source, target); err != nil {
+			m.setError(err)
+			return m, nil
+		}
+		m.reload(target)
+		m.setStatus("Renamed %s to %s", source, target)
+	}
+	return m, nil
+}
+
+func (m AppModel) updateConfirmDelete(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	name := m.selected()
+	m.mode = modeBrowse
+	if msg.String() != "y" && msg.String() != "Y" {
+		m.setStatus("Cancelled")
+		return m, nil
+	}
+	loc, err := editableProfile(name, false)
+	if err == nil {
+		err = removeProfileFile(loc.path)
+	}
+	if err != nil {
+		m.setError(fmt.Errorf("failed to delete profile: %v", err))
+		return m, nil
+	}
+	m.reload("")
+	m.setStatus("Deleted %s", name)
+	return m, nil
+}
+
+func (m AppModel) updateDiffPick(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "esc", "q":
+		m.mode = modeBrowse
+		m.reload(m.diffBase)
+		m.setStatus("Cancelled")
+	case "up", "k":
+		if m.cursor > 0 {
+			m.cursor--
+		}
+	case "down", "j":
+		if m.cursor < len(m.profiles)-1 {
+			m.cursor++
+		}
+	case "enter":
+		other := m.selected()
+		base, err := loadProfileVariables(m.diffBase)
+		if err != nil {
+			m.setError(err)
+			m.mode = modeBrowse
+			return m, nil
+		}
+		target, err := loadProfileVariables(other)
+		if err != nil {
+			m.setError(err)
+			m.mode = modeBrowse
+			return m, nil
+		}
+		m.diff = diffVariables(base, target)
+		m.diffTarget = other
+		m.mode = modeDiff
+		m.reload(m.diffBase)
+		m.setStatus("%d difference(s) between %s and %s", len(m.diff), m.diffBase, other)
+	}
+	return m, nil
+}
+
+func (m AppModel) View() string {
+	if m.err != nil {
+		return fmt.Sprintf("Error: %v\n", m.err)
+	}
+
+	bodyHeight := m.height - 4
+	if bodyHeight < 3 {
+		bodyHeight = 3
+	}
+	rightWidth := m.width - dashboardListWidth - 8
+	if rightWidth < 20 {
+		rightWidth = 20
+	}
+
+	listStyle, previewStyle := activePaneStyle, paneStyle
+	if m.mode == modeDiff {
+		listStyle, previewStyle = paneStyle, activePaneStyle
+	}
+	left := listStyle.Width(dashboardListWidth).Height(bodyHeight).Render(m.renderList(bodyHeight))
+	right := previewStyle.Width(rightWidth).Height(bodyHeight).Render(m.renderPreview(bodyHeight, rightWidth-2))
+
+	title := fmt.Sprintf("%s%s%s %s%s  %d profile(s)", colorBold, colorGreen, ProjectName, Version, colorReset, len(m.profiles))
+	return title + "\n" + lipgloss.JoinHorizontal(lipgloss.Top, left, right) + "\n" + m.renderFooter()
+}
+
+func (m AppModel) renderList(height int) string {
+	if len(m.profiles) == 0 {
+		return fmt.Sprintf("%sNo profiles yet%s\nPress %sn%s to create one", colorYellow, colorReset, colorBold, colorReset)
+	}
+
+	start := 0
+	if m.cursor >= height {
+		start = m.cursor - height + 1
+	}
+	end := start + height
+	if end > len(m.profiles) {
+		end = len(m.profiles)
+	}
+
+	var sb strings.Builder
+	for i := start; i < end; i++ {
+		p := m.profiles[i]
+		nameWidth := dashboardListWidth - 9
+		name := truncate(strings.TrimSuffix(p.name, ".env"), nameWidth)
+		line := fmt.Sprintf("%-*s %4d", nameWidth, name, p.entries)
+		if i == m.cursor {
+			sb.WriteString(fmt.Sprintf("%s%s❯ %s%s", colorBold, colorGreen, line, colorReset))
+		} else {
+			sb.WriteString("  " + line)
+		}
+		if i < end-1 {
+			sb.WriteString("\n")
+		}
+	}
+	return sb.String()
+}
+
+func (m AppModel) renderPreview(height, width int) string {
+	var lines []string
+	if m.mode == modeDiff {
+		lines = append(lines, fmt.Sprintf("%s%s%s → %s%s", colorBold, colorYellow, m.diffBase, m.diffTarget, colorReset))
+		if len(m.diff) == 0 {
+			lines = append(lines, "Profiles are identical")
+		}
+		for _, d := range m.diff {
+			lines = append(lines, m.renderDiffLine(d, width))
+		}
+	} else {
+		name := m.selected()
+		if name == "" {
+			return ""
+		}
+		mask := "secrets masked, m to reveal"
+		if m.revealed() {
+			mask = "secrets revealed, m to mask"
+		}
+		lines = append(lines, fmt.Sprintf("%s%s%s%s (%s)", colorBold, colorYellow, name, colorReset, mask))
+		if len(m.preview) == 0 {
+			lines = append(lines, "(empty profile)")
+		}
+		for _, v := range m.preview {
+			value := m.displayValue(v.Key, v.Value)
+			lines = append(lines, truncate(v.Key+"="+value, width))
+		}
+	}
+
+	if len(lines) > height {
+		lines = append(lines[:height-1], fmt.Sprintf("… %d more", len(lines)-height+1))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// displayValue masks values of keys matching the configured mask patterns
+// unless the user chose to reveal them and no protected profile is shown.
+func (m AppModel) displayValue(key, value string) string {
+	if m.revealed() || !isMaskedKey(m.masks, key) {
+		return value
+	}
+	return maskValue(value)
+}
+
+func (m AppModel) renderDiffLine(d keyDiff, width int) string {
+	oldValue, newValue := m.displayValue(d.key, d.oldValue), m.displayValue(d.key, d.newValue)
+	switch d.kind {
+	case diffAdded:
+		return colorGreen + truncate("+ "+d.key+"="+newValue, width) + colorReset
+	case diffRemoved:
+		return colorRed + truncate("- "+d.key+"="+oldValue, width) + colorReset
+	default:
+		return colorYellow + truncate("~ "+d.key+": "+oldValue+" → "+newValue, width) + colorReset
+	}
+}
+
+func (m AppModel) renderFooter() string {
+	switch m.mode {
+	case modePrompt:
+		return fmt.Sprintf("%s%s%s%s%s█%s  (enter to confirm, esc to cancel)", colorBold, m.promptLabel, colorReset, m.input, colorBold, colorReset)
+	case modeConfirmDelete:
+		return fmt.Sprintf("%s%sDelete profile %s?%s [y/N]", colorRed, colorBold, m.selected(), colorReset)
+	}
+
+	if m.status != "" {
+		color := colorGreen
+		if m.statusErr {
+			color = colorRed
+		}
+		return color + m.status + colorReset
+	}
+	if m.mode == modeDiff {
+		return fmt.Sprintf("%sesc%s back  %sm%s mask/reveal", colorBold, colorReset, colorBold, colorReset)
+	}
+	return fmt.Sprintf("%sn%s new  %se%s edit  %sc%s duplicate  %sr%s rename  %sd%s delete  %sx%s export  %sD%s diff  %sm%s mask  %sq%s quit",
+		colorBold, colorReset,
+		colorBold, colorReset,
+		colorBold, colorReset,
+		colorBold, colorReset,
+		colorBold, colorReset,
+		colorBold, colorReset,
+		colorBold, colorReset,
+		colorBold, colorReset,
+		colorBold, colorReset,
+	)
+}
+
+func truncate(s string, width int) string {
+	runes := []rune(s)
+	if width <= 0 || len(runes) <= width {
+		return s
+	}
+	if width == 1 {
+		return "…"
+	}
+	return string(runes[:width-1]) + "…"
+}
+
+func duplicateProfile(source, target string) error {
+	sourcePath, err := resolveProfilePath(source)
+	if err != nil {
+		return err
+	}
+	content, err := os.ReadFile(sourcePath)
+	if err != nil {
+		return fmt.Errorf("failed to read profile: %v", err)
+	}
+	_, err = writeNewProfile(target, content)
+	return err
+}
+
+func renameProfile(source, target string) error {
+	loc, err := editableProfile(source, false)
+	if err != nil {
+		return err
+	}
+	sourcePath := loc.path
+	targetPath, err := newProfilePath(target)
+	if err != nil {
+		return err
+	}
+	index, err := lockIndex(filepath.Dir(targetPath))
+	if err != nil {
+		return err
+	}
+	defer index.release()
+	lock, err := lockProfile(sourcePath)
+	if err != nil {
+		return err
+	}
+	defer lock.release()
+
+	if _, err := os.Stat(targetPath); err == nil {
+		return fmt.Errorf("profile '%s' already exists at %s", target, targetPath)
+	}
+	if err := os.Rename(sourcePath, targetPath); err != nil {
+		return fmt.Errorf("failed to rename profile: %v", err)
+	}
+	for _, suffix := range profileBackups(sourcePath) {
+		os.Rename(sourcePath+suffix, targetPath+suffix)
+	}
+	if err := renameProfileLists(source, target); err != nil {
+		return fmt.Errorf("renamed the profile but failed to update the config: %v", err)
+	}
+	return nil
+}
+
+// RunDashboard opens the full-screen profile manager.
+func RunDashboard() error {
+	p := tea.NewProgram(NewAppModel(), tea.WithAltScreen())
+	finalModel, err := p.Run()
+	if err != nil {
+		return fmt.Errorf("failed to run dashboard: %v", err)
+	}
+	if m, ok := finalModel.(AppModel); ok && m.err != nil {
+		return m.err
+	}
+	return nil
+}
+
+// dashboardAvailable reports whether stdin and stdout are terminals, so
+// that scripts running envman without arguments still get the help text.
+func dashboardAvailable() bool {
+	for _, f := range []*os.File{os.Stdin, os.Stdout} {
+		info, err := f.Stat()
+		if err != nil || info.Mode()&os.ModeCharDevice == 0 {
+			return false
+		}
+	}
+	return true
+}
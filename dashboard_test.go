@@ -0,0 +1,43 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestRenameProfileMovesBackupsAndSettings(t *testing.T) {
+	dir := testHome(t)
+	files := []string{"a.env", "a.env.bak", "a.env.bak.1", "a.env.bak.3", "ab.env.bak"}
+	for _, name := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(name), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, key := range []string{"review_profiles", "skip_review_profiles"} {
+		if _, err := updateProfileList(key, "a", true); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := renameProfile("a", "b"); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range files[1:4] {
+		content, err := os.ReadFile(filepath.Join(dir, "b"+name[1:]))
+		if err != nil || string(content) != name {
+			t.Errorf("%s was not moved: %q, %v", name, content, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "ab.env.bak")); err != nil {
+		t.Errorf("another profile's backup moved: %v", err)
+	}
+	cfg, err := loadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(cfg.ReviewProfiles, []string{"b"}) || !reflect.DeepEqual(cfg.SkipReviewProfiles, []string{"b"}) {
+		t.Errorf("settings still name the old profile: %v, %v", cfg.ReviewProfiles, cfg.SkipReviewProfiles)
+	}
+}
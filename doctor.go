@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+// profileFilePattern matches profiles and their backups: name.env,
+// name.env.bak and name.env.bak.N.
+var profileFilePattern = regexp.MustCompile(`\.env(\.bak(\.[0-9]+)?)?$`)
+
+// permissionIssue is a file or directory other local users can read.
+type permissionIssue struct {
+	path string
+	mode os.FileMode
+}
+
+func (i permissionIssue) fixedMode() os.FileMode {
+	return i.mode &^ 0077
+}
+
+func describeAccess(mode os.FileMode) string {
+	switch {
+	case mode&0070 != 0 && mode&0007 != 0:
+		return "group and others"
+	case mode&0070 != 0:
+		return "group"
+	}
+	return "others"
+}
+
+// doctorDirs returns every directory envman keeps profiles or backups in.
+func doctorDirs() ([]string, error) {
+	cfg, err := loadConfig()
+	if err != nil {
+		return nil, err
+	}
+	dirs, err := profileSearchPath(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if data, err := dataDir(); err == nil && !containsString(dirs, filepath.Clean(data)) {
+		dirs = append(dirs, filepath.Clean(data))
+	}
+	if recovery, err := recoveryDir(); err == nil && !containsString(dirs, recovery) {
+		dirs = append(dirs, recovery)
+	}
+	if project, err := findProject(); err == nil && project != nil && !containsString(dirs, project.profileDir) {
+		dirs = append(dirs, project.profileDir)
+	}
+	return dirs, nil
+}
+
+// findPermissionIssues lists profile directories, profiles and backups
+// that grant any access to group or others. Symlinks are not followed.
+func findPermissionIssues() ([]permissionIssue, error) {
+	dirs, err := doctorDirs()
+	if err != nil {
+		return nil, err
+	}
+
+	var issues []permissionIssue
+	for _, dir := range dirs {
+		info, err := os.Lstat(dir)
+		if err != nil || !info.IsDir() {
+			continue
+		}
+		if info.Mode().Perm()&0077 != 0 {
+			issues = append(issues, permissionIssue{path: dir, mode: info.Mode().Perm()})
+		}
+
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %v", dir, err)
+		}
+		for _, entry := range entries {
+			if !entry.Type().IsRegular() || !profileFilePattern.MatchString(entry.Name()) {
+				continue
+			}
+			info, err := entry.Info()
+			if err != nil {
+				continue
+			}
+			if info.Mode().Perm()&0077 != 0 {
+				issues = append(issues, permissionIssue{path: filepath.Join(dir, entry.Name()), mode: info.Mode().Perm()})
+			}
+		}
+	}
+	return issues, nil
+}
+
+// Doctor checks the permissions of profiles, backups and their
+// directories, and repairs them with fixPerms.
+func Doctor(fixPerms bool) error {
+	issues, err := findPermissionIssues()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s %s%sChecking permissions of profiles and backups%s\n\n",
+		iconInfo,
+		colorGreen,
+		colorBold,
+		colorReset,
+	)
+	if len(issues) == 0 {
+		fmt.Printf("%s%s%s No problems found%s\n", colorGreen, colorBold, iconCheck, colorReset)
+		return nil
+	}
+
+	failed := 0
+	for _, issue := range issues {
+		if !fixPerms {
+			fmt.Printf("  %s%s%s %s  mode %04o, readable by %s\n",
+				colorRed,
+				iconX,
+				colorReset,
+				displayPath(issue.path),
+				issue.mode,
+				describeAccess(issue.mode),
+			)
+			continue
+		}
+		if err := os.Chmod(issue.path, issue.fixedMode()); err != nil {
+			failed++
+			fmt.Printf("  %s%s%s %s  %v\n", colorRed, iconX, colorReset, displayPath(issue.path), err)
+			continue
+		}
+		fmt.Printf("  %s%s%s %s  %04o -> %04o\n",
+			colorGreen,
+			iconCheck,
+			colorReset,
+			displayPath(issue.path),
+			issue.mode,
+			issue.fixedMode(),
+		)
+	}
+	fmt.Println()
+
+	if !fixPerms {
+		return fmt.Errorf("%d permission problem(s) found, repair them with: envman doctor --fix-perms", len(issues))
+	}
+	if failed > 0 {
+		return fmt.Errorf("could not repair %d of %d path(s)", failed, len(issues))
+	}
+	fmt.Printf("%s%s%s Repaired %d path(s)%s\n", colorGreen, colorBold, iconCheck, len(issues), colorReset)
+	return nil
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rivo/tview"
+)
+
+// diagnosticsDelay is how long typing has to pause before the profile is
+// checked again.
+const diagnosticsDelay = 150 * time.Millisecond
+
+var envReferencePattern = regexp.MustCompile(`\$(?:\{([A-Za-z_][A-Za-z0-9_]*)\}|([A-Za-z_][A-Za-z0-9_]*))`)
+
+type diagSeverity int
+
+const (
+	diagWarning diagSeverity = iota
+	diagError
+)
+
+// diagnostic is a problem on one line (0-based) of a profile.
+type diagnostic struct {
+	line     int
+	severity diagSeverity
+	message  string
+}
+
+// unterminatedQuote returns the quote a value opens without closing, or 0.
+func unterminatedQuote(value string) byte {
+	if value == "" || value[0] != '"' && value[0] != '\'' {
+		return 0
+	}
+	if strings.IndexByte(value[1:], value[0]) < 0 {
+		return value[0]
+	}
+	return 0
+}
+
+// diagnoseProfile checks every line of a profile: lines that are not
+// KEY=value, invalid key names, duplicate keys, unterminated quotes and
+// $VAR and ${VAR} references to variables that are neither in the profile
+// nor in the environment. Single-quoted values are literal, so their
+// references are not checked. Profiles have no schema of required keys or
+// value types yet, so schema violations are not diagnosed.
+func diagnoseProfile(text string) []diagnostic {
+	lines := strings.Split(text, "\n")
+	defined := make(map[string]bool)
+	for _, line := range lines {
+		if key, _, ok := parseEnvLine(line); ok {
+			defined[key] = true
+		}
+	}
+
+	var diags []diagnostic
+	firstLine := make(map[string]int)
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		key, value, literal, ok := parseEnvEntry(line)
+		if !ok {
+			diags = append(diags, diagnostic{i, diagError, "not a KEY=value line"})
+			continue
+		}
+		if !envKeyPattern.MatchString(key) {
+			diags = append(diags, diagnostic{i, diagError, fmt.Sprintf("invalid key '%s': use letters, digits and _, not starting with a digit", key)})
+		}
+		if first, seen := firstLine[key]; seen {
+			diags = append(diags, diagnostic{i, diagWarning, fmt.Sprintf("%s is already defined on line %d; this later value wins", key, first+1)})
+		} else {
+			firstLine[key] = i
+		}
+
+		raw := strings.TrimSpace(strings.SplitN(strings.TrimPrefix(trimmed, "export "), "=", 2)[1])
+		if quote := unterminatedQuote(raw); quote != 0 {
+			diags = append(diags, diagnostic{i, diagError, fmt.Sprintf("unterminated %c quote", quote)})
+			continue
+		}
+		if literal {
+			continue
+		}
+		for _, m := range envReferencePattern.FindAllStringSubmatch(value, -1) {
+			name := m[1] + m[2]
+			if _, inEnv := os.LookupEnv(name); !defined[name] && !inEnv {
+				diags = append(diags, diagnostic{i, diagWarning, fmt.Sprintf("%s is not defined in this profile or the environment", m[0])})
+			}
+		}
+	}
+	return diags
+}
+
+// lineDiagnostics groups diagnostics by line.
+func lineDiagnostics(diags []diagnostic) map[int][]diagnostic {
+	lines := make(map[int][]diagnostic)
+	for _, d := range diags {
+		lines[d.line] = append(lines[d.line], d)
+	}
+	return lines
+}
+
+// scheduleDiagnostics reanalyzes the profile once typing pauses, so large
+// files are not rescanned on every keystroke.
+func (e *Editor) scheduleDiagnostics() {
+	if e.diagTimer != nil {
+		e.diagTimer.Stop()
+	}
+	e.diagTimer = time.AfterFunc(diagnosticsDelay, func() {
+		e.app.QueueUpdateDraw(e.analyze)
+	})
+}
+
+// analyze refreshes everything the gutter shows: diagnostics and the lines
+// changed since the last save.
+func (e *Editor) analyze() {
+	text := e.textArea.GetText()
+	e.updateGutter(text)
+	e.modified = modifiedLines(e.lastBackup, text)
+	e.setDiagnostics(diagnoseProfile(text))
+}
+
+func (e *Editor) setDiagnostics(diags []diagnostic) {
+	e.diagnostics = lineDiagnostics(diags)
+	e.diagnosticCount = len(diags)
+	e.updateStatus()
+	e.updateProblems()
+}
+
+// updateProblems shows the problems on the cursor's line, or how many
+// there are in total.
+func (e *Editor) updateProblems() {
+	row, _, _, _ := e.textArea.GetCursor()
+	if diags := e.diagnostics[row]; len(diags) > 0 {
+		var messages []string
+		for _, d := range diags {
+			messages = append(messages, d.message)
+		}
+		color := "yellow"
+		if maxSeverity(diags) == diagError {
+			color = "red"
+		}
+		e.problems.SetText(fmt.Sprintf("[%s::b]Line %d:[-::-] %s", color, row+1, tview.Escape(strings.Join(messages, "; "))))
+		return
+	}
+	if e.diagnosticCount > 0 {
+		e.problems.SetText(fmt.Sprintf("[gray]%d problem(s). Ctrl+N jumps to the next one.", e.diagnosticCount))
+		return
+	}
+	e.problems.SetText("")
+}
+
+func maxSeverity(diags []diagnostic) diagSeverity {
+	severity := diagWarning
+	for _, d := range diags {
+		if d.severity > severity {
+			severity = d.severity
+		}
+	}
+	return severity
+}
+
+// nextProblem moves the cursor to the next line with a problem after the
+// cursor, wrapping around.
+func (e *Editor) nextProblem() {
+	if len(e.diagnostics) == 0 {
+		e.messages.SetText("[green::b]No problems")
+		return
+	}
+	lines := make([]int, 0, len(e.diagnostics))
+	for line := range e.diagnostics {
+		lines = append(lines, line)
+	}
+	sort.Ints(lines)
+
+	row, _, _, _ := e.textArea.GetCursor()
+	target := lines[0]
+	for _, line := range lines {
+		if line > row {
+			target = line
+			break
+		}
+	}
+	start := 0
+	for i, line := range strings.Split(e.textArea.GetText(), "\n") {
+		if i == target {
+			break
+		}
+		start += len(line) + 1
+	}
+	e.selectRange(start, start)
+	e.updateProblems()
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// maxDiffCells bounds the table of the line diff behind the modified-line
+// markers, which runs whenever typing pauses. Larger edits, such as sorting
+// a big profile, are compared line by line instead.
+const maxDiffCells = 1 << 16
+
+type lineMark int
+
+const (
+	// lineChanged is a line added or changed since the last save.
+	lineChanged lineMark = iota + 1
+	// lineDeletedAbove is a line right after lines deleted since the last
+	// save.
+	lineDeletedAbove
+)
+
+// modifiedLines compares the saved text with the current one line by line
+// and returns marks keyed by line (0-based) of the current text.
+func modifiedLines(saved, current string) map[int]lineMark {
+	a := strings.Split(saved, "\n")
+	b := strings.Split(current, "\n")
+	marks := make(map[int]lineMark)
+
+	// Edits are usually local, so only the middle that differs is diffed.
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	old := a[prefix : len(a)-suffix]
+	new := b[prefix : len(b)-suffix]
+
+	markDeleted := func(line int) {
+		line = min(line, len(b)-1)
+		if marks[line] == 0 {
+			marks[line] = lineDeletedAbove
+		}
+	}
+	if (len(old)+1)*(len(new)+1) > maxDiffCells {
+		for i := range new {
+			if i >= len(old) || old[i] != new[i] {
+				marks[prefix+i] = lineChanged
+			}
+		}
+		if len(new) < len(old) {
+			markDeleted(prefix + len(new))
+		}
+		return marks
+	}
+
+	// lcs[i*width+j] is the length of the longest common subsequence of
+	// old[i:] and new[j:].
+	width := len(new) + 1
+	lcs := make([]int32, (len(old)+1)*width)
+	for i := len(old) - 1; i >= 0; i-- {
+		for j := len(new) - 1; j >= 0; j-- {
+			if old[i] == new[j] {
+				lcs[i*width+j] = lcs[(i+1)*width+j+1] + 1
+			} else {
+				lcs[i*width+j] = max(lcs[(i+1)*width+j], lcs[i*width+j+1])
+			}
+		}
+	}
+
+	// Between two common lines, deleted lines replaced by added ones count
+	// as changed; only a pure deletion gets a deletion marker.
+	added, deleted := false, false
+	endHunk := func(line int) {
+		if deleted && !added {
+			markDeleted(line)
+		}
+		added, deleted = false, false
+	}
+	i, j := 0, 0
+	for i < len(old) || j < len(new) {
+		switch {
+		case i < len(old) && j < len(new) && old[i] == new[j]:
+			endHunk(prefix + j)
+			i++
+			j++
+		case j < len(new) && (i == len(old) || lcs[i*width+j+1] >= lcs[(i+1)*width+j]):
+			marks[prefix+j] = lineChanged
+			added = true
+			j++
+		default:
+			deleted = true
+			i++
+		}
+	}
+	endHunk(prefix + j)
+	return marks
+}
+
+// editorGutter is the column left of the text. Each row shows a problem
+// marker (red for errors, yellow for warnings), the line number and a
+// marker for lines changed since the last save: green for added or changed
+// lines, red for a line after deleted ones.
+type editorGutter struct {
+	*tview.Box
+	editor *Editor
+}
+
+func newEditorGutter(e *Editor) *editorGutter {
+	return &editorGutter{Box: tview.NewBox(), editor: e}
+}
+
+// gutterWidth is the width the gutter needs for lines line numbers.
+func gutterWidth(lines int) int {
+	return len(strconv.Itoa(max(lines, 99))) + 3
+}
+
+func (g *editorGutter) Draw(screen tcell.Screen) {
+	g.Box.DrawForSubclass(screen, g)
+	x, y, width, height := g.GetInnerRect()
+	e := g.editor
+	offset, _ := e.textArea.GetOffset()
+	cursorRow, _, _, _ := e.textArea.GetCursor()
+	digits := width - 3
+
+	for row := 0; row < height; row++ {
+		line := offset + row
+		if line >= e.lineCount {
+			break
+		}
+		if diags := e.diagnostics[line]; len(diags) > 0 {
+			color := tcell.ColorYellow
+			if maxSeverity(diags) == diagError {
+				color = tcell.ColorRed
+			}
+			tview.Print(screen, "●", x, y+row, 1, tview.AlignLeft, color)
+		}
+		numberColor := tcell.ColorGray
+		if line == cursorRow {
+			numberColor = tcell.ColorYellow
+		}
+		tview.Print(screen, strconv.Itoa(line+1), x+1, y+row, digits, tview.AlignRight, numberColor)
+		switch e.modified[line] {
+		case lineChanged:
+			tview.Print(screen, "▌", x+digits+1, y+row, 1, tview.AlignLeft, tcell.ColorGreen)
+		case lineDeletedAbove:
+			tview.Print(screen, "▔", x+digits+1, y+row, 1, tview.AlignLeft, tcell.ColorRed)
+		}
+	}
+}
+
+// gutterFlex holds the gutter and the text area. It draws the gutter and
+// the search highlights again after the text area, whose scroll offset may
+// change while it draws.
+type gutterFlex struct {
+	*tview.Flex
+	gutter *editorGutter
+}
+
+func (f *gutterFlex) Draw(screen tcell.Screen) {
+	f.Flex.Draw(screen)
+	f.gutter.Draw(screen)
+	f.gutter.editor.drawMatches(screen)
+}
+
+// updateGutter resizes the gutter to fit the line numbers of text.
+func (e *Editor) updateGutter(text string) {
+	e.lineCount = strings.Count(text, "\n") + 1
+	e.editorPane.ResizeItem(e.editorPane.gutter, gutterWidth(e.lineCount), 0)
+}
+
+// updatePosition shows the cursor's line and column in the status bar.
+func (e *Editor) updatePosition() {
+	row, column, toRow, toColumn := e.textArea.GetCursor()
+	position := fmt.Sprintf("Ln %d, Col %d", toRow+1, toColumn+1)
+	if row != toRow || column != toColumn {
+		selected, _, _ := e.textArea.GetSelection()
+		position = fmt.Sprintf("%s (%d selected)", position, len([]rune(selected)))
+	}
+	e.position.SetText("[yellow]" + position + " ")
+}
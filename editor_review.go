@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// reviewLine renders one key difference for the save review, masking
+// secret values.
+func (e *Editor) reviewLine(d keyDiff) string {
+	oldValue, newValue := d.oldValue, d.newValue
+	if isMaskedKey(e.config.maskPatterns, d.key) {
+		oldValue, newValue = maskValue(oldValue), maskValue(newValue)
+	}
+	switch d.kind {
+	case diffAdded:
+		return "[green]+ " + tview.Escape(d.key+"="+newValue) + "[white]"
+	case diffRemoved:
+		return "[red]- " + tview.Escape(d.key+"="+oldValue) + "[white]"
+	default:
+		return "[yellow]~ " + tview.Escape(d.key+": "+oldValue+" → "+newValue) + "[white]"
+	}
+}
+
+// saveReviewed saves the buffer like saveChecked, but for profiles that
+// want a review it first lists the keys added, removed and changed since
+// the file was last read or written and waits for confirmation.
+func (e *Editor) saveReviewed(flex *tview.Flex, done func()) {
+	if !e.config.reviewSave {
+		e.saveChecked(flex, done)
+		return
+	}
+	diffs := diffVariables(parseProfileVariables(e.diskContent), parseProfileVariables(e.textArea.GetText()))
+
+	counts := make(map[diffKind]int)
+	var sb strings.Builder
+	for _, d := range diffs {
+		counts[d.kind]++
+		sb.WriteString(e.reviewLine(d) + "\n")
+	}
+	if len(diffs) == 0 {
+		sb.WriteString("[gray]No keys changed; only comments, blank lines or ordering differ.")
+	}
+	changes := tview.NewTextView().
+		SetDynamicColors(true).
+		SetText(sb.String())
+	changes.SetBorder(true).
+		SetTitle(fmt.Sprintf(" Save '%s'? %d added, %d removed, %d changed ",
+			e.config.profileName, counts[diffAdded], counts[diffRemoved], counts[diffChanged])).
+		SetTitleColor(tcell.ColorGreen).
+		SetTitleAlign(tview.AlignLeft)
+
+	back := func() {
+		e.app.SetRoot(flex, true)
+	}
+	buttons := tview.NewForm().
+		AddButton("Save", func() {
+			e.saveChecked(flex, done)
+		}).
+		AddButton("Back", back)
+	buttons.SetCancelFunc(back)
+
+	view := tview.NewFlex().
+		SetDirection(tview.FlexRow).
+		AddItem(changes, 0, 1, false).
+		AddItem(buttons, 3, 1, true)
+	e.app.SetRoot(view, true)
+}
@@ -0,0 +1,443 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/mattn/go-runewidth"
+	"github.com/rivo/tview"
+)
+
+// compileSearch turns a search query into a regular expression. Plain
+// queries match literally.
+func compileSearch(query string, useRegex, matchCase bool) (*regexp.Regexp, error) {
+	if !useRegex {
+		query = regexp.QuoteMeta(query)
+	}
+	if !matchCase {
+		query = "(?i)" + query
+	}
+	re, err := regexp.Compile(query)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regular expression: %v", err)
+	}
+	return re, nil
+}
+
+// findMatches returns the byte ranges of the non-empty matches of re.
+func findMatches(text string, re *regexp.Regexp) [][]int {
+	var matches [][]int
+	for _, m := range re.FindAllStringIndex(text, -1) {
+		if m[1] > m[0] {
+			matches = append(matches, m)
+		}
+	}
+	return matches
+}
+
+// openPanel shows p under the text, replacing any other open panel.
+func (e *Editor) openPanel(flex *tview.Flex, p tview.Primitive, height int) {
+	e.closePanel(flex)
+	e.panel = p
+	flex.AddItem(p, height, 1, true)
+	e.app.SetFocus(p)
+}
+
+// closePanel removes the open panel and returns focus to the text or
+// table view.
+func (e *Editor) closePanel(flex *tview.Flex) {
+	if e.panel != nil {
+		flex.RemoveItem(e.panel)
+		e.panel = nil
+	}
+	e.search = nil
+	e.app.SetFocus(e.pages)
+}
+
+// selectRange selects text[start:end] and scrolls it into view.
+func (e *Editor) selectRange(start, end int) {
+	e.textArea.Select(start, end)
+	row := strings.Count(e.textArea.GetText()[:start], "\n")
+	_, _, _, height := e.textArea.GetInnerRect()
+	offset, _ := e.textArea.GetOffset()
+	if row < offset || row >= offset+height {
+		e.textArea.SetOffset(max(row-height/2, 0), 0)
+	}
+}
+
+// drawMatches highlights every visible match of the open find or replace
+// panel's search on top of the text area. The selected match keeps the
+// selection style.
+func (e *Editor) drawMatches(screen tcell.Screen) {
+	if e.search == nil {
+		return
+	}
+	x, y, width, height := e.textArea.GetInnerRect()
+	rowOffset, columnOffset := e.textArea.GetOffset()
+	_, selectionStart, selectionEnd := e.textArea.GetSelection()
+	style := tcell.StyleDefault.Background(tcell.ColorOlive).Foreground(tcell.ColorBlack)
+
+	start := 0
+	for row, line := range strings.Split(e.textArea.GetText(), "\n") {
+		if row >= rowOffset+height {
+			break
+		}
+		if row >= rowOffset {
+			for _, m := range findMatches(line, e.search) {
+				if start+m[0] == selectionStart && start+m[1] == selectionEnd {
+					continue
+				}
+				column := 0
+				for i, r := range line {
+					if i >= m[1] {
+						break
+					}
+					w := runewidth.RuneWidth(r)
+					if r == '\t' {
+						w = tview.TabSize
+					}
+					for cell := column; i >= m[0] && cell < column+w; cell++ {
+						if cx := x + cell - columnOffset; cx >= x && cx < x+width {
+							mainc, combc, _, _ := screen.GetContent(cx, y+row-rowOffset)
+							screen.SetContent(cx, y+row-rowOffset, mainc, combc, style)
+						}
+					}
+					column += w
+				}
+			}
+		}
+		start += len(line) + 1
+	}
+}
+
+// jumpToMatch selects the match of re nearest to from in direction: 0 for
+// the first at or after from, 1 for the first after it and -1 for the last
+// before it, wrapping around the text.
+func (e *Editor) jumpToMatch(re *regexp.Regexp, from, direction int) bool {
+	matches := findMatches(e.textArea.GetText(), re)
+	if len(matches) == 0 {
+		e.messages.SetText("[red::b]No matches")
+		return false
+	}
+	index := -1
+	switch direction {
+	case -1:
+		for i := len(matches) - 1; i >= 0; i-- {
+			if matches[i][0] < from {
+				index = i
+				break
+			}
+		}
+		if index < 0 {
+			index = len(matches) - 1
+		}
+	default:
+		for i, m := range matches {
+			if m[0] > from || direction == 0 && m[0] == from {
+				index = i
+				break
+			}
+		}
+		if index < 0 {
+			index = 0
+		}
+	}
+	e.selectRange(matches[index][0], matches[index][1])
+	e.messages.SetText(fmt.Sprintf("[green::b]Match %d of %d", index+1, len(matches)))
+	return true
+}
+
+// showFindBar opens a search bar under the text. Typing searches from where
+// the cursor was, Enter or Down jumps to the next match, Up to the previous
+// one, and Esc goes back to the text with the match selected.
+func (e *Editor) showFindBar(flex *tview.Flex) {
+	_, origin, _ := e.textArea.GetSelection()
+	field := tview.NewInputField().
+		SetLabel("Find: ").
+		SetFieldWidth(0)
+
+	search := func(direction int) {
+		query := field.GetText()
+		e.search = nil
+		if query == "" {
+			e.messages.SetText("")
+			return
+		}
+		re, err := compileSearch(query, false, false)
+		if err != nil {
+			e.messages.SetText("[red::b]" + err.Error())
+			return
+		}
+		e.search = re
+		from := origin
+		if direction != 0 {
+			_, from, _ = e.textArea.GetSelection()
+		}
+		e.jumpToMatch(re, from, direction)
+	}
+	field.SetChangedFunc(func(text string) {
+		search(0)
+	})
+	field.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch event.Key() {
+		case tcell.KeyEnter, tcell.KeyDown:
+			search(1)
+			return nil
+		case tcell.KeyUp:
+			search(-1)
+			return nil
+		case tcell.KeyEscape:
+			e.closePanel(flex)
+			return nil
+		}
+		return event
+	})
+	e.openPanel(flex, field, 1)
+}
+
+// lineChange is one line a replace-all would change.
+type lineChange struct {
+	line     int
+	old, new string
+}
+
+// replaceInLines replaces every match of re line by line, expanding $1
+// style references when useRegex is set. Matches never span lines.
+func replaceInLines(text string, re *regexp.Regexp, replacement string, useRegex bool) (string, []lineChange) {
+	lines := strings.Split(text, "\n")
+	var changes []lineChange
+	for i, line := range lines {
+		var replaced string
+		if useRegex {
+			replaced = re.ReplaceAllString(line, replacement)
+		} else {
+			replaced = re.ReplaceAllLiteralString(line, replacement)
+		}
+		if replaced != line {
+			changes = append(changes, lineChange{line: i + 1, old: line, new: replaced})
+			lines[i] = replaced
+		}
+	}
+	return strings.Join(lines, "\n"), changes
+}
+
+// showReplacePanel opens find and replace under the text. Replace changes
+// the selected match and moves to the next one; Replace all shows every
+// changed line for confirmation first.
+func (e *Editor) showReplacePanel(flex *tview.Flex) {
+	form := tview.NewForm().
+		AddInputField("Find", "", 40, nil, nil).
+		AddInputField("Replace with", "", 40, nil, nil).
+		AddCheckbox("Regex", false, nil).
+		AddCheckbox("Match case", false, nil)
+	form.SetItemPadding(0).
+		SetBorder(true).
+		SetTitle(" Replace ").
+		SetTitleAlign(tview.AlignLeft)
+
+	pattern := func() (*regexp.Regexp, string, bool, error) {
+		query := form.GetFormItem(0).(*tview.InputField).GetText()
+		replacement := form.GetFormItem(1).(*tview.InputField).GetText()
+		useRegex := form.GetFormItem(2).(*tview.Checkbox).IsChecked()
+		matchCase := form.GetFormItem(3).(*tview.Checkbox).IsChecked()
+		e.search = nil
+		if query == "" {
+			return nil, "", false, fmt.Errorf("nothing to find")
+		}
+		re, err := compileSearch(query, useRegex, matchCase)
+		if err == nil {
+			e.search = re
+		}
+		return re, replacement, useRegex, err
+	}
+	form.AddButton("Next", func() {
+		re, _, _, err := pattern()
+		if err != nil {
+			e.messages.SetText("[red::b]" + err.Error())
+			return
+		}
+		_, from, _ := e.textArea.GetSelection()
+		e.jumpToMatch(re, from, 1)
+	})
+	form.AddButton("Replace", func() {
+		re, replacement, useRegex, err := pattern()
+		if err != nil {
+			e.messages.SetText("[red::b]" + err.Error())
+			return
+		}
+		selected, start, end := e.textArea.GetSelection()
+		if m := re.FindStringIndex(selected); m == nil || m[0] != 0 || m[1] != len(selected) || selected == "" {
+			// Nothing or something else is selected: find a match first.
+			e.jumpToMatch(re, start, 0)
+			return
+		}
+		if useRegex {
+			replacement = re.ReplaceAllString(selected, replacement)
+		}
+		e.textArea.Replace(start, end, replacement)
+		e.jumpToMatch(re, start+len(replacement), 0)
+	})
+	form.AddButton("Replace all", func() {
+		re, replacement, useRegex, err := pattern()
+		if err != nil {
+			e.messages.SetText("[red::b]" + err.Error())
+			return
+		}
+		text := e.textArea.GetText()
+		replaced, changes := replaceInLines(text, re, replacement, useRegex)
+		if len(changes) == 0 {
+			e.messages.SetText("[red::b]No matches")
+			return
+		}
+		e.showReplacePreview(flex, changes, func() {
+			e.textArea.Replace(0, len(text), replaced)
+			e.closePanel(flex)
+			e.messages.SetText(fmt.Sprintf("[green::b]Replaced matches on %d line(s)", len(changes)))
+		})
+	})
+	closePanel := func() {
+		e.closePanel(flex)
+	}
+	form.AddButton("Close", closePanel)
+	form.SetCancelFunc(closePanel)
+
+	e.openPanel(flex, form, 8)
+}
+
+// showReplacePreview lists the lines a replace-all would change, old above
+// new, and runs apply once the user confirms.
+func (e *Editor) showReplacePreview(flex *tview.Flex, changes []lineChange, apply func()) {
+	var sb strings.Builder
+	for _, c := range changes {
+		sb.WriteString(fmt.Sprintf("[yellow]%4d[white] [red]- %s[white]\n", c.line, tview.Escape(c.old)))
+		sb.WriteString(fmt.Sprintf("     [green]+ %s[white]\n", tview.Escape(c.new)))
+	}
+	preview := tview.NewTextView().
+		SetDynamicColors(true).
+		SetText(sb.String())
+	preview.SetBorder(true).
+		SetTitle(fmt.Sprintf(" Replace all: %d line(s) change ", len(changes))).
+		SetTitleColor(tcell.ColorGreen).
+		SetTitleAlign(tview.AlignLeft)
+
+	back := func() {
+		e.app.SetRoot(flex, true)
+		if e.panel != nil {
+			e.app.SetFocus(e.panel)
+		}
+	}
+	buttons := tview.NewForm().
+		AddButton("Apply", func() {
+			back()
+			apply()
+		}).
+		AddButton("Back", back)
+	buttons.SetCancelFunc(back)
+
+	view := tview.NewFlex().
+		SetDirection(tview.FlexRow).
+		AddItem(preview, 0, 1, false).
+		AddItem(buttons, 3, 1, true)
+	e.app.SetRoot(view, true)
+}
+
+// keyLocation is where a key is defined in the editor's text.
+type keyLocation struct {
+	key   string
+	line  int
+	start int
+}
+
+func (e *Editor) keyLocations() []keyLocation {
+	var keys []keyLocation
+	pos := 0
+	for i, line := range strings.Split(e.textArea.GetText(), "\n") {
+		if key, _, ok := parseEnvLine(line); ok {
+			keys = append(keys, keyLocation{key: key, line: i + 1, start: pos + strings.Index(line, key)})
+		}
+		pos += len(line) + 1
+	}
+	return keys
+}
+
+// showGoToKey lists the profile's keys, filtered by fuzzy name as the user
+// types, and jumps to the chosen one.
+func (e *Editor) showGoToKey(flex *tview.Flex) {
+	keys := e.keyLocations()
+	field := tview.NewInputField().
+		SetLabel("Key: ").
+		SetFieldWidth(0)
+	list := tview.NewList().
+		ShowSecondaryText(false).
+		SetHighlightFullLine(true)
+
+	var shown []keyLocation
+	filter := func(query string) {
+		type scored struct {
+			loc   keyLocation
+			score int
+		}
+		var matches []scored
+		for _, loc := range keys {
+			if score, ok := fuzzyScore(loc.key, query); ok {
+				matches = append(matches, scored{loc, score})
+			}
+		}
+		if query != "" {
+			sort.SliceStable(matches, func(i, j int) bool {
+				return matches[i].score > matches[j].score
+			})
+		}
+		shown = shown[:0]
+		list.Clear()
+		for _, m := range matches {
+			shown = append(shown, m.loc)
+			list.AddItem(fmt.Sprintf("%-40s [gray]line %d", tview.Escape(m.loc.key), m.loc.line), "", 0, nil)
+		}
+	}
+	filter("")
+
+	back := func() {
+		e.app.SetRoot(flex, true)
+		e.app.SetFocus(e.textArea)
+	}
+	jump := func() {
+		index := list.GetCurrentItem()
+		if index < 0 || index >= len(shown) {
+			return
+		}
+		back()
+		loc := shown[index]
+		e.selectRange(loc.start, loc.start+len(loc.key))
+		e.messages.SetText(fmt.Sprintf("[green::b]%s[white] on line %d", loc.key, loc.line))
+	}
+
+	field.SetChangedFunc(filter)
+	field.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch event.Key() {
+		case tcell.KeyDown, tcell.KeyUp, tcell.KeyPgDn, tcell.KeyPgUp:
+			list.InputHandler()(event, nil)
+			return nil
+		case tcell.KeyEnter:
+			jump()
+			return nil
+		case tcell.KeyEscape:
+			back()
+			return nil
+		}
+		return event
+	})
+
+	view := tview.NewFlex().
+		SetDirection(tview.FlexRow).
+		AddItem(field, 1, 1, true).
+		AddItem(list, 0, 1, false)
+	view.SetBorder(true).
+		SetTitle(fmt.Sprintf(" Go to key (%d keys) ", len(keys))).
+		SetTitleColor(tcell.ColorGreen).
+		SetTitleAlign(tview.AlignLeft)
+	e.app.SetRoot(view, true)
+}
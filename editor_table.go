@@ -0,0 +1,365 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// maxCopySuffix is how many numbered _COPY names duplicating a row tries.
+const maxCopySuffix = 99
+
+// tableRow is one key in the editor's table view. before holds the raw
+// lines between the previous key and this one, such as blank lines and the
+// key's comment, and raw the key's own line, so unchanged rows render back
+// byte for byte.
+type tableRow struct {
+	before  []string
+	raw     string
+	key     string
+	value   string
+	literal bool
+}
+
+// tableDoc is a profile split into table rows. trailer holds the lines
+// after the last key.
+type tableDoc struct {
+	rows    []tableRow
+	trailer []string
+}
+
+func parseTableDoc(text string) tableDoc {
+	var doc tableDoc
+	var pending []string
+	for _, line := range strings.Split(text, "\n") {
+		key, value, literal, ok := parseEnvEntry(line)
+		if !ok {
+			pending = append(pending, line)
+			continue
+		}
+		doc.rows = append(doc.rows, tableRow{before: pending, raw: line, key: key, value: value, literal: literal})
+		pending = nil
+	}
+	doc.trailer = pending
+	return doc
+}
+
+func (d tableDoc) text() string {
+	var lines []string
+	for _, r := range d.rows {
+		lines = append(lines, r.before...)
+		lines = append(lines, r.raw)
+	}
+	lines = append(lines, d.trailer...)
+	return strings.Join(lines, "\n")
+}
+
+// commentLines counts the comment lines directly above the key.
+func (r tableRow) commentLines() int {
+	n := 0
+	for i := len(r.before) - 1; i >= 0 && strings.HasPrefix(strings.TrimSpace(r.before[i]), "#"); i-- {
+		n++
+	}
+	return n
+}
+
+// comment is the key's comment: the comment lines directly above it.
+func (r tableRow) comment() string {
+	var parts []string
+	for _, line := range r.before[len(r.before)-r.commentLines():] {
+		parts = append(parts, strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line), "#")))
+	}
+	return strings.Join(parts, " ")
+}
+
+func (r *tableRow) setComment(comment string) {
+	before := append([]string{}, r.before[:len(r.before)-r.commentLines()]...)
+	if comment != "" {
+		before = append(before, "# "+comment)
+	}
+	r.before = before
+}
+
+// set rewrites the key's line. A renamed key keeps the rest of the line as
+// written; a new value is quoted the way the old one was, expanding or
+// literal, and keeps a leading "export " and a trailing comment.
+func (r *tableRow) set(key, value string) {
+	eq := strings.Index(r.raw, "=")
+	switch {
+	case eq < 0:
+		r.raw = formatEnvValue(key, value, r.literal)
+	case value == r.value:
+		head := r.raw[:eq]
+		at := strings.LastIndex(head, r.key)
+		r.raw = head[:at] + key + head[at+len(r.key):] + r.raw[eq:]
+	default:
+		prefix := ""
+		if strings.HasPrefix(strings.TrimSpace(r.raw), "export ") {
+			prefix = "export "
+		}
+		_, comment := splitEnvValue(strings.TrimSpace(r.raw[eq+1:]))
+		r.raw = prefix + formatEnvValue(key, value, r.literal) + comment
+	}
+	r.key = key
+	r.value = value
+}
+
+// defined reports whether a row other than index defines key.
+func (d tableDoc) defined(index int, key string) bool {
+	for i, r := range d.rows {
+		if i != index && r.key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// validateTableKey checks a key entered for row index (-1 for a new row).
+func validateTableKey(doc tableDoc, index int, key string) error {
+	if !envKeyPattern.MatchString(key) {
+		return fmt.Errorf("invalid key '%s': use letters, digits and _, not starting with a digit", key)
+	}
+	if (index < 0 || doc.rows[index].key != key) && doc.defined(index, key) {
+		return fmt.Errorf("%s is already defined", key)
+	}
+	return nil
+}
+
+// validateTableEntry checks a key and value entered for row index (-1 for a
+// new row) before they are written.
+func validateTableEntry(doc tableDoc, index int, key, value string) error {
+	if err := validateTableKey(doc, index, key); err != nil {
+		return err
+	}
+	if index >= 0 && doc.rows[index].value == value {
+		// The value's line is kept as written.
+		return nil
+	}
+	literal := index >= 0 && doc.rows[index].literal
+	return checkEnvValue(key, value, literal)
+}
+
+func (e *Editor) tableMode() bool {
+	front, _ := e.pages.GetFrontPage()
+	return front == "table"
+}
+
+// toggleTable switches between the text and the table view. Both edit the
+// same text, so switching never loses anything.
+func (e *Editor) toggleTable() {
+	if e.tableMode() {
+		e.pages.SwitchToPage("text")
+		e.app.SetFocus(e.textArea)
+	} else {
+		e.refreshTable()
+		e.pages.SwitchToPage("table")
+		e.app.SetFocus(e.table)
+	}
+	e.updateStatus()
+}
+
+// refreshTable redraws the table from the text, keeping the selection.
+func (e *Editor) refreshTable() {
+	doc := parseTableDoc(e.textArea.GetText())
+	row, column := e.table.GetSelection()
+	e.table.Clear()
+	for i, title := range []string{"Key", "Value", "Comment"} {
+		e.table.SetCell(0, i, tview.NewTableCell(title).
+			SetTextColor(tcell.ColorYellow).
+			SetAttributes(tcell.AttrBold).
+			SetSelectable(false))
+	}
+	for i, r := range doc.rows {
+		value := tview.Escape(r.value)
+		if !e.revealValues && isMaskedKey(e.config.maskPatterns, r.key) {
+			value = maskValue(r.value)
+		}
+		e.table.SetCell(i+1, 0, tview.NewTableCell(tview.Escape(r.key)).SetTextColor(tcell.ColorGreen))
+		e.table.SetCell(i+1, 1, tview.NewTableCell(value).SetMaxWidth(60))
+		e.table.SetCell(i+1, 2, tview.NewTableCell(tview.Escape(r.comment())).SetTextColor(tcell.ColorGray).SetExpansion(1))
+	}
+	if len(doc.rows) == 0 {
+		return
+	}
+	e.table.Select(min(max(row, 1), len(doc.rows)), column)
+}
+
+// applyTableDoc writes doc back to the text, as one undoable change, and
+// selects row index in column.
+func (e *Editor) applyTableDoc(doc tableDoc, index, column int) {
+	text := e.textArea.GetText()
+	if updated := doc.text(); updated != text {
+		e.textArea.Replace(0, len(text), updated)
+	}
+	e.refreshTable()
+	if index >= 0 && index < len(doc.rows) {
+		e.table.Select(index+1, column)
+	}
+}
+
+// editTableCell edits one cell of row index under the table. then runs
+// after a valid value was stored.
+func (e *Editor) editTableCell(flex *tview.Flex, index, column int, then func()) {
+	doc := parseTableDoc(e.textArea.GetText())
+	if index < 0 || index >= len(doc.rows) {
+		return
+	}
+	r := doc.rows[index]
+	label, initial := "Key: ", r.key
+	switch column {
+	case 1:
+		label, initial = "Value of "+r.key+": ", r.value
+	case 2:
+		label, initial = "Comment for "+r.key+": ", r.comment()
+	}
+
+	field := tview.NewInputField().
+		SetLabel(label).
+		SetText(initial).
+		SetFieldWidth(0)
+	field.SetDoneFunc(func(key tcell.Key) {
+		if key == tcell.KeyEscape {
+			e.closePanel(flex)
+			return
+		}
+		if key != tcell.KeyEnter {
+			return
+		}
+		doc := parseTableDoc(e.textArea.GetText())
+		row := &doc.rows[index]
+		var err error
+		switch column {
+		case 0:
+			name := strings.TrimSpace(field.GetText())
+			if err = validateTableEntry(doc, index, name, row.value); err == nil {
+				row.set(name, row.value)
+			}
+		case 1:
+			if err = validateTableEntry(doc, index, row.key, field.GetText()); err == nil {
+				row.set(row.key, field.GetText())
+			}
+		case 2:
+			row.setComment(strings.TrimSpace(field.GetText()))
+		}
+		if err != nil {
+			e.messages.SetText("[red::b]" + err.Error())
+			return
+		}
+		e.closePanel(flex)
+		e.applyTableDoc(doc, index, column)
+		e.messages.SetText("")
+		if then != nil {
+			then()
+		}
+	})
+	e.openPanel(flex, field, 1)
+}
+
+// addTableRow asks for a new key, inserts it at index and then asks for
+// its value.
+func (e *Editor) addTableRow(flex *tview.Flex, index int) {
+	field := tview.NewInputField().
+		SetLabel("New key: ").
+		SetFieldWidth(0)
+	field.SetDoneFunc(func(key tcell.Key) {
+		if key == tcell.KeyEscape {
+			e.closePanel(flex)
+			return
+		}
+		if key != tcell.KeyEnter {
+			return
+		}
+		name := strings.TrimSpace(field.GetText())
+		doc := parseTableDoc(e.textArea.GetText())
+		if err := validateTableEntry(doc, -1, name, ""); err != nil {
+			e.messages.SetText("[red::b]" + err.Error())
+			return
+		}
+		row := tableRow{}
+		row.set(name, "")
+		index = min(index, len(doc.rows))
+		doc.rows = append(doc.rows[:index], append([]tableRow{row}, doc.rows[index:]...)...)
+		e.closePanel(flex)
+		e.applyTableDoc(doc, index, 1)
+		e.editTableCell(flex, index, 1, nil)
+	})
+	e.openPanel(flex, field, 1)
+}
+
+// handleTableKey runs the table view's commands.
+func (e *Editor) handleTableKey(flex *tview.Flex, event *tcell.EventKey) *tcell.EventKey {
+	row, column := e.table.GetSelection()
+	index := row - 1
+	doc := parseTableDoc(e.textArea.GetText())
+
+	command := event.Rune()
+	switch {
+	case event.Key() == tcell.KeyEnter:
+		command = 'e'
+	case event.Key() == tcell.KeyUp && event.Modifiers()&tcell.ModShift != 0:
+		command = 'K'
+	case event.Key() == tcell.KeyDown && event.Modifiers()&tcell.ModShift != 0:
+		command = 'J'
+	case event.Key() != tcell.KeyRune:
+		return event
+	}
+
+	if command == 'v' {
+		e.revealValues = !e.revealValues
+		e.refreshTable()
+		return nil
+	}
+	if !strings.ContainsRune("eacdKJ", command) {
+		return event
+	}
+	if e.config.readOnly != "" {
+		e.messages.SetText("[yellow::b]Read-only: " + e.config.readOnly)
+		return nil
+	}
+	if command == 'a' {
+		e.addTableRow(flex, index+1)
+		return nil
+	}
+	if index < 0 || index >= len(doc.rows) {
+		return nil
+	}
+
+	switch command {
+	case 'e':
+		e.editTableCell(flex, index, column, nil)
+	case 'c':
+		copied := doc.rows[index]
+		copied.before = append([]string{}, copied.before[len(copied.before)-copied.commentLines():]...)
+		name := copied.key + "_COPY"
+		for i := 2; doc.defined(-1, name) && i <= maxCopySuffix; i++ {
+			name = fmt.Sprintf("%s_COPY%d", copied.key, i)
+		}
+		// The copy keeps the line as written, so only its key can be wrong.
+		if err := validateTableKey(doc, -1, name); err != nil {
+			e.messages.SetText("[red::b]Cannot duplicate " + tview.Escape(copied.key) + ": " + tview.Escape(err.Error()))
+			return nil
+		}
+		copied.set(name, copied.value)
+		doc.rows = append(doc.rows[:index+1], append([]tableRow{copied}, doc.rows[index+1:]...)...)
+		e.applyTableDoc(doc, index+1, 0)
+		e.editTableCell(flex, index+1, 0, nil)
+	case 'd':
+		key := doc.rows[index].key
+		doc.rows = append(doc.rows[:index], doc.rows[index+1:]...)
+		e.applyTableDoc(doc, min(index, len(doc.rows)-1), column)
+		e.messages.SetText(fmt.Sprintf("[yellow::b]Deleted %s.[white] Ctrl+Z in text mode (Ctrl+T) undoes it.", key))
+	case 'K', 'J':
+		target := index - 1
+		if command == 'J' {
+			target = index + 1
+		}
+		if target < 0 || target >= len(doc.rows) {
+			return nil
+		}
+		doc.rows[index], doc.rows[target] = doc.rows[target], doc.rows[index]
+		e.applyTableDoc(doc, target, column)
+	}
+	return nil
+}
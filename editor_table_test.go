@@ -0,0 +1,66 @@
+package main
+
+import "testing"
+
+const tableProfile = `# header
+
+  export A=1   # inline
+B=$HOME/bin
+C='${HOME}' # literal
+D="x y"
+
+# trailing comment
+`
+
+func TestTableDocRoundTrip(t *testing.T) {
+	for _, text := range []string{tableProfile, "", "A=1", "\n\n# only comments\n", "broken line\nA=1\n"} {
+		if got := parseTableDoc(text).text(); got != text {
+			t.Errorf("round trip of %q gave %q", text, got)
+		}
+	}
+}
+
+func TestTableRowSetKeepsLine(t *testing.T) {
+	doc := parseTableDoc(tableProfile)
+	tests := []struct {
+		index      int
+		key, value string
+		raw        string
+	}{
+		// Renames keep the rest of the line as written.
+		{0, "A2", "1", "  export A2=1   # inline"},
+		{1, "B_COPY", "$HOME/bin", "B_COPY=$HOME/bin"},
+		// New values keep the row's quoting style and comment.
+		{1, "B", "$HOME/sbin", `B="$HOME/sbin"`},
+		{1, "B", "$HOME/my bin", `B="$HOME/my bin"`},
+		{2, "C", "${HOME}/x", "C='${HOME}/x' # literal"},
+		{0, "A", "2", "export A=2   # inline"},
+	}
+	for _, tt := range tests {
+		row := doc.rows[tt.index]
+		row.set(tt.key, tt.value)
+		if row.raw != tt.raw {
+			t.Errorf("set(%q, %q) on %q = %q, want %q", tt.key, tt.value, doc.rows[tt.index].raw, row.raw, tt.raw)
+		}
+		key, value, literal, ok := parseEnvEntry(row.raw)
+		if !ok || key != tt.key || value != tt.value || literal != doc.rows[tt.index].literal {
+			t.Errorf("%q reads back as %q=%q (literal %v)", row.raw, key, value, literal)
+		}
+	}
+}
+
+func TestValidateTableEntry(t *testing.T) {
+	doc := parseTableDoc("my-key=1\nA=\"it's $HOME\"\nB=2\n")
+	if err := validateTableKey(doc, -1, "my-key_COPY"); err == nil {
+		t.Error("an invalid key was accepted")
+	}
+	if err := validateTableKey(doc, -1, "B"); err == nil {
+		t.Error("a duplicate key was accepted")
+	}
+	if err := validateTableEntry(doc, 1, "A", "it's $USER"); err != nil {
+		t.Errorf("expanding value rejected: %v", err)
+	}
+	if err := validateTableEntry(doc, 2, "B", "a\nb"); err == nil {
+		t.Error("a value with a line break was accepted")
+	}
+}
@@ -1,79 +1,98 @@
 package main
 
 import (
-	"fmt"
-	tea "github.com/charmbracelet/bubbletea"
-	"os"
-	"os/user"
-	"path/filepath"
 	"strings"
 )
 
-func EnsureConfig() error {
-	currentUser, err := user.Current()
+// getEnvmanRoot returns the writable profile directory.
+func getEnvmanRoot() (string, error) {
+	cfg, err := loadConfig()
 	if err != nil {
-		return fmt.Errorf("failed to get current user: %v", err)
+		return "", err
 	}
+	return writeProfileDir(cfg)
+}
 
-	configDir := filepath.Join("/home", currentUser.Username, ".config", ProjectName)
-	configFile := filepath.Join(configDir, configFileName)
+// parseEnvLine splits a KEY=VALUE line from a profile. Blank lines, comments
+// and lines without '=' are reported as not ok. A leading "export ", a
+// single layer of matching quotes around the value and a trailing comment
+// after an unquoted or quoted value are stripped.
+func parseEnvLine(line string) (string, string, bool) {
+	key, value, _, ok := parseEnvEntry(line)
+	return key, value, ok
+}
 
-	if err := os.MkdirAll(configDir, 0755); err != nil {
-		return fmt.Errorf("failed to create config directory: %v", err)
+// parseEnvEntry is parseEnvLine that also reports whether the value was
+// single-quoted, in which case the shell would not expand references in it.
+func parseEnvEntry(line string) (string, string, bool, bool) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return "", "", false, false
 	}
-
-	if _, err := os.Stat(configFile); os.IsNotExist(err) {
-		content := fmt.Sprintf(configTemplate, currentUser.Username)
-		if err := os.WriteFile(configFile, []byte(content), 0644); err != nil {
-			return fmt.Errorf("failed to create config file: %v", err)
-		}
+	line = strings.TrimPrefix(line, "export ")
+	parts := strings.SplitN(line, "=", 2)
+	if len(parts) != 2 {
+		return "", "", false, false
 	}
-
-	return nil
+	key := strings.TrimSpace(parts[0])
+	if key == "" {
+		return "", "", false, false
+	}
+	value, literal := parseEnvValue(strings.TrimSpace(parts[1]))
+	return key, value, literal, true
 }
 
-func (m AppModel) View() string {
-
-	if m.err != nil {
-		return fmt.Sprintf("Error: %v\n", m.err)
+// parseEnvValue unquotes a raw value the way sourcing the line would: a
+// quoted value ends at its closing quote, and in an unquoted value a '#'
+// after whitespace starts a comment.
+func parseEnvValue(raw string) (string, bool) {
+	written, _ := splitEnvValue(raw)
+	if len(written) >= 2 && (written[0] == '"' || written[0] == '\'') && written[len(written)-1] == written[0] {
+		return written[1 : len(written)-1], written[0] == '\''
 	}
-	return helpText
+	return written, false
 }
 
-func (m AppModel) Init() tea.Cmd {
-	return nil
+// splitEnvValue splits a raw value into the value as written, quotes
+// included, and a trailing comment along with the whitespace before it.
+func splitEnvValue(raw string) (string, string) {
+	if raw == "" {
+		return "", ""
+	}
+	if quote := raw[0]; quote == '"' || quote == '\'' {
+		if end := strings.IndexByte(raw[1:], quote); end >= 0 {
+			rest := raw[end+2:]
+			if rest == "" || (rest[0] == ' ' || rest[0] == '\t') && strings.HasPrefix(strings.TrimSpace(rest), "#") {
+				return raw[:end+2], rest
+			}
+		}
+		return raw, ""
+	}
+	for i := 1; i < len(raw); i++ {
+		if raw[i] == '#' && (raw[i-1] == ' ' || raw[i-1] == '\t') {
+			written := strings.TrimRight(raw[:i], " \t")
+			return written, raw[len(written):]
+		}
+	}
+	return raw, ""
 }
 
-func (m AppModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
-	switch msg := msg.(type) {
-	case tea.KeyMsg:
-		if msg.Type == tea.KeyCtrlC {
-			return m, tea.Quit
+func parseProfileVariables(content string) []profileVariable {
+	vars := []profileVariable{}
+	for _, line := range strings.Split(content, "\n") {
+		if key, value, literal, ok := parseEnvEntry(line); ok {
+			vars = append(vars, profileVariable{Key: key, Value: value, literal: literal, line: strings.TrimSpace(line)})
 		}
 	}
-	return m, nil
+	return vars
 }
-func getEnvmanRoot() (string, error) {
-	currentUser, err := user.Current()
-	if err != nil {
-		return "", fmt.Errorf("failed to get current user: %v", err)
-	}
-	configFilePath := fmt.Sprintf("/home/%s/.config/%s/%s", currentUser.Username, ProjectName, configFileName)
-	configContent, err := os.ReadFile(configFilePath)
+
+// resolveProfilePath returns the path of an existing profile on the search
+// path.
+func resolveProfilePath(name string) (string, error) {
+	loc, err := findProfile(name)
 	if err != nil {
-		return "", fmt.Errorf("failed to read config file: %v", err)
-	}
-	var profileDir string
-	for _, line := range strings.Split(string(configContent), "\n") {
-		if strings.HasPrefix(line, "PROFILE_DIR=") {
-			profileDir = strings.TrimPrefix(line, "PROFILE_DIR=")
-			profileDir = strings.Split(profileDir, "#")[0]
-			profileDir = strings.TrimSpace(profileDir)
-			break
-		}
-	}
-	if profileDir == "" {
-		return "", fmt.Errorf("PROFILE_DIR not found in config")
+		return "", err
 	}
-	return profileDir, nil
+	return loc.path, nil
 }
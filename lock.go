@@ -0,0 +1,152 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Writers take an advisory flock on a lock file for the profile, so
+// concurrent set, import and editor saves apply one after the other instead
+// of losing updates. Creating, renaming and deleting profiles also lock the
+// profile's directory. Readers take no lock: writes replace the profile
+// with a rename, so a reader always sees either the old or the new file.
+// Lock files live in the data directory rather than next to what they
+// guard, so none end up in project directories that get committed.
+const lockDirName = "locks"
+
+const lockRetryInterval = 50 * time.Millisecond
+
+type fileLock struct {
+	file *os.File
+}
+
+// lockFilePath returns the lock file guarding target: a file in the data
+// directory named after a hash of target's absolute path. Symlinked
+// directories are resolved so every route to a file shares one lock.
+func lockFilePath(target string) (string, error) {
+	dir, err := dataDir()
+	if err != nil {
+		return "", err
+	}
+	abs, err := filepath.Abs(target)
+	if err != nil {
+		return "", err
+	}
+	if parent, err := filepath.EvalSymlinks(filepath.Dir(abs)); err == nil {
+		abs = filepath.Join(parent, filepath.Base(abs))
+	}
+	sum := sha256.Sum256([]byte(abs))
+	return filepath.Join(dir, lockDirName, hex.EncodeToString(sum[:8])+".lock"), nil
+}
+
+// lockProfile waits for exclusive write access to a profile.
+func lockProfile(profilePath string) (*fileLock, error) {
+	return acquireLock(profilePath, "profile "+displayPath(profilePath))
+}
+
+// lockConfig waits for exclusive write access to the config file.
+func lockConfig(configFile string) (*fileLock, error) {
+	return acquireLock(configFile, "config "+displayPath(configFile))
+}
+
+// lockIndex waits for exclusive access to the set of profiles in dir.
+func lockIndex(dir string) (*fileLock, error) {
+	return acquireLock(dir, "profiles in "+displayPath(dir))
+}
+
+// acquireLock takes an exclusive flock on target's lock file, retrying
+// until lock_timeout runs out. The holder writes a description of itself
+// into the file so a process that times out can say who it was waiting for.
+func acquireLock(target, what string) (*fileLock, error) {
+	timeout := 10 * time.Second
+	if cfg, err := loadConfig(); err == nil {
+		timeout = time.Duration(cfg.LockTimeout) * time.Second
+	}
+
+	path, err := lockFilePath(target)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), profileDirMode); err != nil {
+		return nil, fmt.Errorf("failed to create lock directory: %v", err)
+	}
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, profileFileMode)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file: %v", err)
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+		if err == nil {
+			break
+		}
+		if err != syscall.EWOULDBLOCK {
+			file.Close()
+			return nil, fmt.Errorf("failed to lock %s: %v", what, err)
+		}
+		if time.Now().After(deadline) {
+			file.Close()
+			return nil, fmt.Errorf("timed out after %s waiting for the lock on %s, held by %s", timeout, what, lockHolder(path))
+		}
+		time.Sleep(lockRetryInterval)
+	}
+
+	file.Truncate(0)
+	file.WriteAt([]byte(describeLockHolder()), 0)
+	return &fileLock{file: file}, nil
+}
+
+// release clears the holder description and unlocks.
+func (l *fileLock) release() {
+	l.file.Truncate(0)
+	syscall.Flock(int(l.file.Fd()), syscall.LOCK_UN)
+	l.file.Close()
+}
+
+func describeLockHolder() string {
+	host, _ := os.Hostname()
+	return fmt.Sprintf("envman %s (pid %d on %s, since %s)",
+		strings.Join(os.Args[1:], " "),
+		os.Getpid(),
+		host,
+		time.Now().Format("15:04:05"),
+	)
+}
+
+func lockHolder(path string) string {
+	content, err := os.ReadFile(path)
+	if err != nil || strings.TrimSpace(string(content)) == "" {
+		return "another process"
+	}
+	return strings.TrimSpace(string(content))
+}
+
+// updateProfile applies change to a profile's content while holding its
+// lock, so concurrent updates cannot overwrite each other.
+func updateProfile(profilePath string, change func(content string) (string, error)) error {
+	lock, err := lockProfile(profilePath)
+	if err != nil {
+		return err
+	}
+	defer lock.release()
+
+	content, err := os.ReadFile(profilePath)
+	if err != nil {
+		return fmt.Errorf("failed to read profile: %v", err)
+	}
+	updated, err := change(string(content))
+	if err != nil {
+		return err
+	}
+	if err := saveContent(profilePath, updated); err != nil {
+		return fmt.Errorf("failed to write profile: %v", err)
+	}
+	return nil
+}
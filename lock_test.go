@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestUpdateProfileSerializesWriters(t *testing.T) {
+	dir := testHome(t)
+	path := filepath.Join(dir, "p.env")
+	if err := os.WriteFile(path, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(line string) {
+			defer wg.Done()
+			err := updateProfile(path, func(content string) (string, error) {
+				return content + line + "\n", nil
+			})
+			if err != nil {
+				t.Error(err)
+			}
+		}(fmt.Sprintf("K%d=1", i))
+	}
+	wg.Wait()
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := splitLines(string(content)); len(lines) != 20 {
+		t.Errorf("%d of 20 concurrent updates kept:\n%s", len(lines), content)
+	}
+}
+
+func TestLocksStayOutOfProfileDirs(t *testing.T) {
+	dir := testHome(t)
+	project := filepath.Join(t.TempDir(), ".envman")
+	if err := os.MkdirAll(project, 0700); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(project, "p.env")
+
+	index, err := lockIndex(project)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lock, err := lockProfile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lock.release()
+	index.release()
+
+	if entries, _ := os.ReadDir(project); len(entries) != 0 {
+		t.Errorf("lock files written to the profile directory: %v", entries)
+	}
+	profileLock, _ := lockFilePath(path)
+	indexLock, _ := lockFilePath(project)
+	if profileLock == indexLock || !strings.HasPrefix(profileLock, filepath.Join(dir, lockDirName)+string(filepath.Separator)) {
+		t.Errorf("lock files %s and %s", profileLock, indexLock)
+	}
+
+	// A symlinked directory leads to the same lock.
+	link := filepath.Join(t.TempDir(), "link")
+	if err := os.Symlink(project, link); err != nil {
+		t.Fatal(err)
+	}
+	if viaLink, _ := lockFilePath(filepath.Join(link, "p.env")); viaLink != profileLock {
+		t.Errorf("lock via symlink %s, want %s", viaLink, profileLock)
+	}
+}
+
+func TestWriteFileAtomicKeepsMode(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "p.env")
+	if err := os.WriteFile(path, []byte("A=1\n"), 0640); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(path, 0640); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeFileAtomic(path, []byte("A=2\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil || string(content) != "A=2\n" {
+		t.Errorf("content = %q, %v", content, err)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0640 {
+		t.Errorf("mode = %v, %v; want 0640", info.Mode(), err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("temporary files left behind: %v", entries)
+	}
+
+	created := filepath.Join(dir, "new.env")
+	if err := writeFileAtomic(created, []byte("B=1\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Stat(created); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("new file mode = %v, %v; want 0600", info.Mode(), err)
+	}
+}
@@ -10,12 +10,20 @@ func main() {
 		fmt.Fprintf(os.Stderr, "Error initializing config: %v\n", err)
 		os.Exit(1)
 	}
+	if cfg, err := loadConfig(); err == nil {
+		applyTheme(cfg.Theme)
+	}
 
-	for _, arg := range os.Args[1:] {
-		if arg == "-v" || arg == "--version" {
-			fmt.Printf("%s %s\n", ProjectName, Version)
-			return
+	if len(os.Args) > 1 && (os.Args[1] == "-v" || os.Args[1] == "--version") {
+		fmt.Printf("%s %s\n", ProjectName, Version)
+		return
+	}
+
+	if len(os.Args) <= 1 && dashboardAvailable() {
+		if err := RunDashboard(); err != nil {
+			exitWithError(err)
 		}
+		return
 	}
 
 	if len(os.Args) <= 1 || os.Args[1] == "-h" || os.Args[1] == "--help" {
@@ -41,11 +49,20 @@ func main() {
 		return
 	}
 	if len(os.Args) > 2 && os.Args[1] == "profile" && os.Args[2] == "delete" {
-		if len(os.Args) != 4 {
-			fmt.Println("Usage: envman profile delete <profile-name>")
+		args, allowProtected := extractFlag(os.Args[3:], flagAllowProtected)
+		if len(args) > 1 {
+			fmt.Println("Usage: envman profile delete [profile-name] [--allow-protected]")
 			return
 		}
-		if err := DeleteProfile(os.Args[3]); err != nil {
+		var profileName string
+		if len(args) == 1 {
+			profileName = args[0]
+		}
+		profileName, err := pickProfileIfEmpty(profileName, "delete")
+		if err == nil {
+			err = DeleteProfile(profileName, allowProtected)
+		}
+		if err != nil {
 			fmt.Fprintf(os.Stderr, "%s%s%s Error:%s %v\n",
 				colorRed,
 				colorBold,
@@ -59,7 +76,11 @@ func main() {
 	}
 
 	if len(os.Args) > 2 && os.Args[1] == "profile" && os.Args[2] == "list" {
-		if err := ListProfiles(); err != nil {
+		format, _, err := parseOutputFlag(os.Args[3:])
+		if err == nil {
+			err = ListProfiles(format)
+		}
+		if err != nil {
 			fmt.Fprintf(os.Stderr, "%s%s%s Error:%s %v\n",
 				colorRed,
 				colorBold,
@@ -72,11 +93,20 @@ func main() {
 		return
 	}
 	if len(os.Args) > 2 && os.Args[1] == "profile" && os.Args[2] == "edit" {
-		if len(os.Args) != 4 {
-			fmt.Println("Usage: envman profile edit <profile-name>")
+		args, allowProtected := extractFlag(os.Args[3:], flagAllowProtected)
+		if len(args) > 1 {
+			fmt.Println("Usage: envman profile edit [profile-name] [--allow-protected]")
 			return
 		}
-		if err := EditProfile(os.Args[3]); err != nil {
+		var profileName string
+		if len(args) == 1 {
+			profileName = args[0]
+		}
+		profileName, err := pickProfileIfEmpty(profileName, "edit")
+		if err == nil {
+			err = EditProfile(profileName, allowProtected)
+		}
+		if err != nil {
 			fmt.Fprintf(os.Stderr, "%s%s%sError:%s %v\n",
 				colorRed,
 				colorBold,
@@ -88,12 +118,40 @@ func main() {
 		}
 		return
 	}
+	if len(os.Args) > 2 && os.Args[1] == "profile" && (os.Args[2] == "readonly" || os.Args[2] == "writable") {
+		if len(os.Args) != 4 {
+			fmt.Printf("Usage: envman profile %s <profile-name>\n", os.Args[2])
+			return
+		}
+		if err := SetReadOnly(os.Args[3], os.Args[2] == "readonly"); err != nil {
+			exitWithError(err)
+		}
+		return
+	}
+	if len(os.Args) > 2 && os.Args[1] == "profile" && (os.Args[2] == "protect" || os.Args[2] == "unprotect") {
+		if len(os.Args) != 4 {
+			fmt.Printf("Usage: envman profile %s <profile-name>\n", os.Args[2])
+			return
+		}
+		if err := SetProtected(os.Args[3], os.Args[2] == "protect"); err != nil {
+			exitWithError(err)
+		}
+		return
+	}
 	if len(os.Args) > 2 && os.Args[1] == "profile" && os.Args[2] == "view" {
-		var profileName string
-		if len(os.Args) == 4 {
-			profileName = os.Args[3]
+		args, yes := extractFlag(os.Args[3:], flagYesIMeanProd)
+		format, args, err := parseOutputFlag(args)
+		if err == nil {
+			var profileName string
+			if len(args) == 1 {
+				profileName = args[0]
+			}
+			profileName, err = pickProfileIfEmpty(profileName, "view")
+			if err == nil {
+				err = ViewProfile(profileName, format, yes)
+			}
 		}
-		if err := ViewProfile(profileName); err != nil {
+		if err != nil {
 			fmt.Fprintf(os.Stderr, "%s%s%sError:%s %v\n",
 				colorRed,
 				colorBold,
@@ -105,6 +163,24 @@ func main() {
 		}
 		return
 	}
+	if len(os.Args) > 2 && os.Args[1] == "profile" && os.Args[2] == "path" {
+		if len(os.Args) != 4 {
+			fmt.Println("Usage: envman profile path <profile-name>")
+			return
+		}
+		profilePath, err := resolveProfilePath(os.Args[3])
+		if err != nil {
+			exitWithError(err)
+		}
+		fmt.Println(profilePath)
+		return
+	}
+	if len(os.Args) > 2 && os.Args[1] == "init" && os.Args[2] == "--project" {
+		if err := InitProject(); err != nil {
+			exitWithError(err)
+		}
+		return
+	}
 	if len(os.Args) > 1 && os.Args[1] == "init" {
 		shell := detectShell()
 		forShell := false
@@ -140,5 +216,217 @@ func main() {
 		return
 	}
 
+	if os.Args[1] == "config" {
+		if err := ConfigCommand(os.Args[2:]); err != nil {
+			exitWithError(err)
+		}
+		return
+	}
+	if os.Args[1] == "hook" {
+		shell := detectShell()
+		if len(os.Args) > 2 {
+			shell = os.Args[2]
+		}
+		if err := AutoloadHook(shell); err != nil {
+			exitWithError(err)
+		}
+		return
+	}
+	if os.Args[1] == "allow" || os.Args[1] == "deny" {
+		var dir string
+		if len(os.Args) > 2 {
+			dir = os.Args[2]
+		}
+		var err error
+		if os.Args[1] == "allow" {
+			err = AllowAutoload(dir)
+		} else {
+			err = DenyAutoload(dir)
+		}
+		if err != nil {
+			exitWithError(err)
+		}
+		return
+	}
+	if os.Args[1] == "pick" {
+		action := "use"
+		if len(os.Args) > 2 {
+			action = os.Args[2]
+		}
+		name, err := PickProfile(action)
+		if err != nil {
+			exitWithError(err)
+		}
+		fmt.Println(name)
+		return
+	}
+	if os.Args[1] == "status" {
+		var err error
+		if len(os.Args) > 2 && os.Args[2] == "--check" {
+			ReloadHint()
+		} else if len(os.Args) > 2 && os.Args[2] == "--prompt" {
+			shell := detectShell()
+			if len(os.Args) > 3 {
+				shell = os.Args[3]
+			}
+			err = PromptSegment(shell)
+		} else {
+			err = Status()
+		}
+		if err != nil {
+			exitWithError(err)
+		}
+		return
+	}
+	if os.Args[1] == "shell" {
+		profiles, yes := extractFlag(os.Args[2:], flagYesIMeanProd)
+		if len(profiles) == 0 {
+			name, err := PickProfile("open a shell with")
+			if err != nil {
+				exitWithError(err)
+			}
+			profiles = []string{name}
+		}
+		if err := confirmProtected(profiles, "open a shell with", yes); err != nil {
+			exitWithError(err)
+		}
+		if err := SubShell(profiles); err != nil {
+			exitWithError(err)
+		}
+		return
+	}
+	if os.Args[1] == "reload" {
+		shell := detectShell()
+		args, yes := extractFlag(os.Args[2:], flagYesIMeanProd)
+		if len(args) > 1 && args[0] == "--shell" {
+			shell = args[1]
+		}
+		if err := ReloadProfiles(shell, yes); err != nil {
+			exitWithError(err)
+		}
+		return
+	}
+	if os.Args[1] == "set" || os.Args[1] == "unset" || os.Args[1] == "import" {
+		args, allowProtected := extractFlag(os.Args[2:], flagAllowProtected)
+		var err error
+		switch {
+		case os.Args[1] == "set" && len(args) >= 2:
+			err = SetVariables(args[0], args[1:], allowProtected)
+		case os.Args[1] == "unset" && len(args) >= 2:
+			err = UnsetVariables(args[0], args[1:], allowProtected)
+		case os.Args[1] == "import" && len(args) == 2:
+			err = ImportProfile(args[0], args[1], allowProtected)
+		default:
+			fmt.Println("Usage:\n  envman set <profile> KEY=value...\n  envman unset <profile> KEY...\n  envman import <profile> <file|->")
+			return
+		}
+		if err != nil {
+			exitWithError(err)
+		}
+		return
+	}
+	if os.Args[1] == "doctor" {
+		_, fixPerms := extractFlag(os.Args[2:], "--fix-perms")
+		if err := Doctor(fixPerms); err != nil {
+			exitWithError(err)
+		}
+		return
+	}
+	if os.Args[1] == "stack" {
+		if err := StackCommand(os.Args[2:]); err != nil {
+			exitWithError(err)
+		}
+		return
+	}
+	if os.Args[1] == "export" {
+		shell := detectShell()
+		forLoad := false
+		var profiles []string
+		args, yes := extractFlag(os.Args[2:], flagYesIMeanProd)
+		for i := 0; i < len(args); i++ {
+			switch {
+			case args[i] == "--shell" && i+1 < len(args):
+				shell = args[i+1]
+				i++
+			case args[i] == "--load":
+				forLoad = true
+			default:
+				profiles = append(profiles, args[i])
+			}
+		}
+		if len(profiles) == 0 {
+			name, err := PickProfile("export")
+			if err != nil {
+				exitWithError(err)
+			}
+			profiles = []string{name}
+		}
+		action := "export"
+		if forLoad {
+			action = "load"
+		}
+		if err := confirmProtected(profiles, action, yes); err != nil {
+			exitWithError(err)
+		}
+		if err := ExportProfiles(profiles, shell, forLoad); err != nil {
+			exitWithError(err)
+		}
+		return
+	}
+	if os.Args[1] == "exec" {
+		// Profiles are everything before "--". Without "--" the first
+		// argument is the profile and the rest is the command.
+		var profiles, command []string
+		args := os.Args[2:]
+		yes := false
+		if len(args) > 0 && args[0] == flagYesIMeanProd {
+			args, yes = args[1:], true
+		}
+		split := -1
+		for i, arg := range args {
+			if arg == "--" {
+				split = i
+				break
+			}
+		}
+		if split >= 0 {
+			profiles, command = args[:split], args[split+1:]
+			var found bool
+			profiles, found = extractFlag(profiles, flagYesIMeanProd)
+			yes = yes || found
+		} else if len(args) > 0 {
+			profiles, command = args[:1], args[1:]
+		}
+		if len(command) == 0 {
+			fmt.Println("Usage: envman exec [profile...] -- <command> [args...]")
+			return
+		}
+		if len(profiles) == 0 {
+			name, err := PickProfile("exec")
+			if err != nil {
+				exitWithError(err)
+			}
+			profiles = []string{name}
+		}
+		if err := confirmProtected(profiles, "exec", yes); err != nil {
+			exitWithError(err)
+		}
+		if err := ExecProfiles(profiles, command); err != nil {
+			exitWithError(err)
+		}
+		return
+	}
+
 	fmt.Printf("Command '%s' not yet implemented or incorrect command\n", os.Args[1])
 }
+
+func exitWithError(err error) {
+	fmt.Fprintf(os.Stderr, "%s%s%sError:%s %v\n",
+		colorRed,
+		colorBold,
+		iconX,
+		colorReset,
+		err,
+	)
+	os.Exit(1)
+}
@@ -1,5 +1,25 @@
 package main
 
+// AppModel is the state of the full-screen dashboard shown when envman is
+// run without arguments.
 type AppModel struct {
-	err error
+	err       error
+	profiles  []ProfileInfo
+	cursor    int
+	width     int
+	height    int
+	preview   []profileVariable
+	reveal    bool
+	masks     []string
+	mode      dashboardMode
+	status    string
+	statusErr bool
+
+	promptAction string
+	promptLabel  string
+	input        string
+
+	diffBase   string
+	diffTarget string
+	diff       []keyDiff
 }
@@ -5,19 +5,35 @@ import "fmt"
 const (
 	Version        = "v0.1.0"
 	ProjectName    = "envman"
-	configFileName = "config"
-)
+	configFileName = "config.toml"
 
-const configTemplate = `PROFILE_DIR=/home/%s/.envman/ #Keep the leading slash`
+	legacyConfigFileName = "config"
+)
 
 var helpText = fmt.Sprintf(`%s %s
 Usage:
   %s [command] [flags]
 
+Run envman without arguments in a terminal to open the profile dashboard
+(n new, e edit, c duplicate, r rename, d delete, x export, D diff).
+
 Available Commands:
   init        Initialize envman in your shell
   profile     Manage environment profiles
-  load        Load a profile into the current shell
+  load        Load one or more profiles into the current shell
+  export      Print profiles as shell export statements
+  exec        Run a command with one or more profiles' variables
+  set         Set KEY=value pairs in a profile
+  unset       Remove keys from a profile
+  import      Create or update a profile from a .env file (- for stdin)
+  shell       Start a subshell with profiles applied
+  status      Show the profiles loaded in this shell
+  reload      Re-apply loaded profiles after editing them
+  stack       Manage named stacks of profiles (create, list, show, delete)
+  config      Show or change settings (get, set, list, edit, path)
+  allow       Trust the .envman declaration for this directory
+  deny        Stop trusting the .envman declaration for this directory
+  doctor      Check profile and backup permissions (--fix-perms repairs them)
 
 Profile Subcommands:
   create      Create a new environment profile
@@ -25,22 +41,59 @@ Profile Subcommands:
   show        Display profile contents
   delete      Delete an environment profile
   list        List all available profiles
+  path        Print the file a profile name resolves to
+  protect     Require confirmation before a profile is used
+  unprotect   Remove the protection from a profile
+  readonly    Stop set, unset, import, delete and the editor changing a profile
+  writable    Allow changes to a read-only profile again
 
 Examples:
   # Initialize envman
   $ envman init
+  $ envman init --project               # Add .envman/ and envman.toml here
 
   # Profile Management
   $ envman profile create server-test    # Create new profile
+  $ envman profile create project:dev    # Create a profile in the project
   $ envman profile list                  # List all profiles
   $ envman profile show server-test      # Show profile contents
   $ envman profile edit server-test      # Edit existing profile
   $ envman profile delete server-test    # Delete profile
+  $ envman set server-test PORT=8080     # Change one value
+  $ envman import server-test .env       # Merge keys from a .env file
+
+  # Machine-readable output (json, yaml, tsv, names)
+  $ envman profile list -o json          # List profiles as JSON
+  $ envman profile list -o names         # One profile name per line
+  $ envman profile view server-test -o yaml
+
+  # Configuration
+  $ envman config list                  # Show all settings
+  $ envman config set editor vim        # Change a setting
+  $ envman profile protect prod         # Confirm before loading prod
 
   # Load Profile
   $ envman load server-test             # Load profile into current shell
+  $ envman load                         # Pick a profile interactively
+  $ envman export server-test           # Print export statements
+  $ envman exec server-test -- make run # Run a command with the profile
+  $ envman load base dev local          # Apply left to right, later values win
+  $ envman exec base dev -- make run    # Several profiles need "--"
+  $ envman stack create web-dev base + postgres-local + stripe-test
+  $ envman load web-dev                 # A stack loads like a profile
+  $ envman shell prod                   # Subshell with prod; exit to drop it
+  $ envman status                       # What is loaded here, and is it stale?
+  $ envman reload                       # Pick up edits to loaded profiles
+  $ envman doctor --fix-perms           # Make profiles private to you
+
+  Leaving out the profile name for load, edit, view, delete, export,
+  exec or shell opens a fuzzy-searchable profile picker.
 
 Flags:
+  -o, --output  Output format for list/view: table, json, yaml, tsv, names
+  --allow-protected  Let edit, set, unset, import and delete change a protected profile
+  --yes-i-mean-prod  Use a protected profile without confirmation
+                     (required when there is no terminal)
   -h, --help    Display help information
   -v, --version Display version information
 `, ProjectName, Version, ProjectName)
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+)
+
+// homeDir returns $HOME, falling back to the home directory in the user
+// database when it is unset.
+func homeDir() (string, error) {
+	if home := os.Getenv("HOME"); home != "" {
+		return home, nil
+	}
+	currentUser, err := user.Current()
+	if err != nil {
+		return "", fmt.Errorf("failed to get current user: %v", err)
+	}
+	if currentUser.HomeDir == "" {
+		return "", fmt.Errorf("cannot determine home directory for %s", currentUser.Username)
+	}
+	return currentUser.HomeDir, nil
+}
+
+// configFilePath resolves the config file from $ENVMAN_CONFIG, then
+// $XDG_CONFIG_HOME/envman/config, then $HOME/.config/envman/config.
+func configFilePath() (string, error) {
+	if path := os.Getenv("ENVMAN_CONFIG"); path != "" {
+		return expandPath(path)
+	}
+	if xdg := os.Getenv("XDG_CONFIG_HOME"); xdg != "" {
+		return filepath.Join(xdg, ProjectName, configFileName), nil
+	}
+	home, err := homeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".config", ProjectName, configFileName), nil
+}
+
+// dataDir resolves the directory holding profiles by default from
+// $ENVMAN_HOME, then $XDG_DATA_HOME/envman, then $HOME/.envman.
+func dataDir() (string, error) {
+	if dir := os.Getenv("ENVMAN_HOME"); dir != "" {
+		return expandPath(dir)
+	}
+	if xdg := os.Getenv("XDG_DATA_HOME"); xdg != "" {
+		return filepath.Join(xdg, ProjectName), nil
+	}
+	home, err := homeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, "."+ProjectName), nil
+}
+
+// legacyConfigFilePaths lists where envman v0.1.0 may have written its
+// config: the plain "config" file next to configFile, and the hard-coded
+// /home/<user> location.
+func legacyConfigFilePaths(configFile string) []string {
+	paths := []string{filepath.Join(filepath.Dir(configFile), legacyConfigFileName)}
+	if currentUser, err := user.Current(); err == nil {
+		legacy := filepath.Join("/home", currentUser.Username, ".config", ProjectName, legacyConfigFileName)
+		if legacy != paths[0] {
+			paths = append(paths, legacy)
+		}
+	}
+	return paths
+}
+
+// expandPath expands a leading ~ and environment variables in path.
+func expandPath(path string) (string, error) {
+	path = os.ExpandEnv(path)
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		home, err := homeDir()
+		if err != nil {
+			return "", err
+		}
+		path = filepath.Join(home, path[1:])
+	}
+	return path, nil
+}
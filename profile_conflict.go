@@ -0,0 +1,237 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+func contentHash(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// syncDisk records content as what the profile on disk holds. It is the
+// base that later external changes are detected and merged against.
+func (e *Editor) syncDisk(content string) {
+	e.diskContent = content
+	e.diskHash = contentHash(content)
+	if info, err := os.Stat(e.config.filePath); err == nil {
+		e.config.lastMod = info.ModTime()
+	}
+}
+
+// externalChange returns the profile's content when something other than
+// this editor changed it since it was opened or last saved. A new mtime
+// with the same content, such as from touch, does not count.
+func (e *Editor) externalChange() (string, bool) {
+	info, err := os.Stat(e.config.filePath)
+	if err != nil || info.ModTime().Equal(e.config.lastMod) {
+		return "", false
+	}
+	content, err := os.ReadFile(e.config.filePath)
+	if err != nil {
+		return "", false
+	}
+	if contentHash(string(content)) == e.diskHash {
+		e.config.lastMod = info.ModTime()
+		return "", false
+	}
+	return string(content), true
+}
+
+// saveChecked saves the buffer unless the profile changed on disk, in which
+// case the user first chooses how to resolve that. done runs after a
+// successful save. A read-only profile is never written.
+func (e *Editor) saveChecked(flex *tview.Flex, done func()) {
+	if e.config.readOnly != "" {
+		e.app.SetRoot(flex, true)
+		e.messages.SetText("[yellow::b]Read-only: " + e.config.readOnly + ". Ctrl+S saves a copy as a new profile.")
+		return
+	}
+	lock, err := lockProfile(e.config.filePath)
+	if err != nil {
+		e.app.SetRoot(flex, true)
+		e.messages.SetText("[red::b]Error saving: " + err.Error())
+		return
+	}
+	defer lock.release()
+
+	if disk, changed := e.externalChange(); changed {
+		e.showConflictDialog(flex, disk, done)
+		return
+	}
+	e.app.SetRoot(flex, true)
+	if err := e.save(); err != nil {
+		e.messages.SetText("[red::b]Error saving: " + err.Error())
+		return
+	}
+	e.hasChanges = false
+	e.config.unsavedChanges = false
+	e.removeRecovery()
+	e.updateStatus()
+	done()
+}
+
+// reloadFromDisk replaces the buffer with the profile's current content,
+// discarding unsaved edits.
+func (e *Editor) reloadFromDisk(flex *tview.Flex, disk string) {
+	e.textArea.SetText(disk, false)
+	e.syncDisk(disk)
+	e.lastBackup = disk
+	e.hasChanges = false
+	e.updateStatus()
+	e.app.SetRoot(flex, true)
+	e.messages.SetText("[yellow::b]Reloaded from disk; your unsaved changes were discarded.")
+}
+
+func (e *Editor) showConflictDialog(flex *tview.Flex, disk string, done func()) {
+	modal := tview.NewModal().
+		SetText(fmt.Sprintf("'%s' was changed on disk since you opened it.\n\nOverwrite it with your version, reload it and discard your changes, or compare both side by side?", e.config.profileName)).
+		AddButtons([]string{"Overwrite", "Reload", "Merge", "Cancel"}).
+		SetDoneFunc(func(buttonIndex int, buttonLabel string) {
+			switch buttonLabel {
+			case "Overwrite":
+				e.syncDisk(disk)
+				e.saveChecked(flex, done)
+			case "Reload":
+				e.reloadFromDisk(flex, disk)
+			case "Merge":
+				e.showMergeView(flex, disk, done)
+			default:
+				e.app.SetRoot(flex, true)
+			}
+		})
+	e.app.SetRoot(modal, false)
+}
+
+// showMergeView shows the version on disk next to the buffer, so the user
+// can keep either or combine them.
+func (e *Editor) showMergeView(flex *tview.Flex, disk string, done func()) {
+	mine := e.textArea.GetText()
+	buttons := tview.NewForm().
+		AddButton("Keep mine", func() {
+			e.syncDisk(disk)
+			e.saveChecked(flex, done)
+		}).
+		AddButton("Use disk", func() {
+			e.reloadFromDisk(flex, disk)
+		}).
+		AddButton("Combine", func() {
+			merged, conflicts := mergeExternal(e.diskContent, disk, mine)
+			e.textArea.SetText(merged, false)
+			e.syncDisk(disk)
+			e.hasChanges = true
+			e.updateStatus()
+			e.app.SetRoot(flex, true)
+			if len(conflicts) > 0 {
+				e.messages.SetText("[yellow::b]Combined; kept your value for " + strings.Join(conflicts, ", ") + ". Review and save.")
+			} else {
+				e.messages.SetText("[green::b]Combined the changes from disk into your version. Review and save.")
+			}
+		}).
+		AddButton("Back", func() {
+			e.app.SetRoot(flex, true)
+		})
+	e.app.SetRoot(compareView(" On disk ", disk, " Your version ", mine,
+		"Combine takes disk changes to keys you left alone.", buttons), true)
+}
+
+// compareView lays out two versions of a profile side by side with lines
+// for keys that differ highlighted: yellow when both define the key, green
+// when only that side does. buttons go underneath.
+func compareView(leftTitle, left, rightTitle, right, hint string, buttons *tview.Form) *tview.Flex {
+	diffs := diffVariables(parseProfileVariables(left), parseProfileVariables(right))
+	kinds := make(map[string]diffKind)
+	for _, d := range diffs {
+		kinds[d.key] = d.kind
+	}
+
+	pane := func(title, content string, onlyHere diffKind) *tview.TextView {
+		var sb strings.Builder
+		for _, line := range strings.Split(content, "\n") {
+			key, _, ok := parseEnvLine(line)
+			kind, differs := kinds[key]
+			switch {
+			case ok && differs && kind == diffChanged:
+				sb.WriteString("[yellow]" + tview.Escape(line) + "[white]")
+			case ok && differs && kind == onlyHere:
+				sb.WriteString("[green]" + tview.Escape(line) + "[white]")
+			default:
+				sb.WriteString(tview.Escape(line))
+			}
+			sb.WriteString("\n")
+		}
+		view := tview.NewTextView().
+			SetDynamicColors(true).
+			SetText(sb.String())
+		view.SetBorder(true).
+			SetTitle(title).
+			SetTitleColor(tcell.ColorGreen).
+			SetTitleAlign(tview.AlignLeft)
+		return view
+	}
+
+	help := tview.NewTextView().
+		SetDynamicColors(true).
+		SetText("[yellow]Yellow: changed keys, green: keys on one side only. " + hint)
+
+	return tview.NewFlex().
+		SetDirection(tview.FlexRow).
+		AddItem(tview.NewFlex().
+			AddItem(pane(leftTitle, left, diffRemoved), 0, 1, false).
+			AddItem(pane(rightTitle, right, diffAdded), 0, 1, false), 0, 1, false).
+		AddItem(help, 1, 1, false).
+		AddItem(buttons, 3, 1, true)
+}
+
+// mergeExternal applies the changes made on disk since base to mine. Keys
+// that both sides changed in different ways keep mine's value and are
+// returned as conflicts.
+func mergeExternal(base, disk, mine string) (string, []string) {
+	baseValues := make(map[string]string)
+	for _, v := range parseProfileVariables(base) {
+		baseValues[v.Key] = v.Value
+	}
+	mineValues := make(map[string]string)
+	for _, v := range parseProfileVariables(mine) {
+		mineValues[v.Key] = v.Value
+	}
+	// Keys taken from disk keep their line as written there.
+	diskVars := make(map[string]profileVariable)
+	for _, v := range parseProfileVariables(disk) {
+		diskVars[v.Key] = v
+	}
+
+	var set []profileVariable
+	var unset, conflicts []string
+	for _, d := range diffVariables(parseProfileVariables(base), parseProfileVariables(disk)) {
+		baseValue, inBase := baseValues[d.key]
+		mineValue, inMine := mineValues[d.key]
+		untouched := inBase == inMine && baseValue == mineValue
+		switch {
+		case untouched && d.kind == diffRemoved:
+			unset = append(unset, d.key)
+		case untouched:
+			set = append(set, diskVars[d.key])
+		case d.kind == diffRemoved && !inMine:
+		case d.kind != diffRemoved && inMine && mineValue == d.newValue:
+		default:
+			conflicts = append(conflicts, d.key)
+		}
+	}
+
+	merged := mine
+	if len(set) > 0 {
+		merged, _, _ = setProfileValues(merged, set)
+	}
+	if len(unset) > 0 {
+		merged, _ = unsetProfileKeys(merged, unset)
+	}
+	return merged, conflicts
+}
@@ -4,15 +4,14 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
-	"os/user"
 	"path/filepath"
 	"strings"
 
 	tea "github.com/charmbracelet/bubbletea"
 )
 
-// ANSI
-const (
+// ANSI colors. They are variables so that the mono theme can clear them.
+var (
 	colorRed    = "\033[31m"
 	colorGreen  = "\033[32m"
 	colorYellow = "\033[33m"
@@ -45,11 +44,8 @@ func (m CreateProfileModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		case "q", "ctrl+c":
 			return m, tea.Quit
 		case "e":
-			editor := "nano"
-			if os.Getenv("EDITOR") != "" {
-				editor = os.Getenv("EDITOR")
-			}
-			cmd := exec.Command(editor, m.profilePath)
+			editor := externalEditor()
+			cmd := exec.Command(editor[0], append(editor[1:], m.profilePath)...)
 			cmd.Stdin = os.Stdin
 			cmd.Stdout = os.Stdout
 			cmd.Stderr = os.Stderr
@@ -92,52 +88,86 @@ func (m CreateProfileModel) View() string {
 	return "\n"
 }
 
-func CreateProfile(name string) error {
-	if name == "" {
-		return fmt.Errorf("profile name cannot be empty")
-	}
-
+// newProfilePath validates name and returns the path a new profile with that
+// name would be written to.
+func newProfilePath(name string) (string, error) {
 	name = strings.TrimSpace(name)
-	if strings.Contains(name, "/") {
-		return fmt.Errorf("profile name cannot contain '/'")
+	if err := validateProfileName(name); err != nil {
+		return "", err
 	}
-
-	currentUser, err := user.Current()
-	if err != nil {
-		return fmt.Errorf("failed to get current user: %v", err)
+	if loc, err := findProfile(name); err == nil {
+		return "", fmt.Errorf("profile '%s' already exists at %s", name, loc.path)
+	}
+	if stackExists(name) {
+		return "", fmt.Errorf("'%s' is already the name of a stack", name)
 	}
 
-	configPath := filepath.Join("/home", currentUser.Username, ".config", ProjectName, configFileName)
-	configContent, err := os.ReadFile(configPath)
+	profileDir, err := getEnvmanRoot()
 	if err != nil {
-		return fmt.Errorf("failed to read config file: %v", err)
+		return "", err
 	}
-
-	var profileDir string
-	for _, line := range strings.Split(string(configContent), "\n") {
-		if strings.HasPrefix(line, "PROFILE_DIR=") {
-			profileDir = strings.TrimPrefix(line, "PROFILE_DIR=")
-			profileDir = strings.Split(profileDir, "#")[0]
-			profileDir = strings.TrimSpace(profileDir)
-			break
+	fileName := name
+	if isProjectProfileName(name) {
+		project, err := requireProject()
+		if err != nil {
+			return "", err
+		}
+		profileDir = project.profileDir
+		fileName = strings.TrimPrefix(name, projectProfilePrefix)
+		if err := validateProfileName(fileName); err != nil {
+			return "", err
 		}
 	}
 
-	if profileDir == "" {
-		return fmt.Errorf("PROFILE_DIR not found in config")
+	if err := os.MkdirAll(profileDir, profileDirMode); err != nil {
+		return "", fmt.Errorf("failed to create profiles directory: %v", err)
 	}
 
-	if err := os.MkdirAll(profileDir, 0755); err != nil {
-		return fmt.Errorf("failed to create profiles directory: %v", err)
+	profilePath := filepath.Join(profileDir, fileName+".env")
+	if _, err := os.Stat(profilePath); err == nil {
+		return "", fmt.Errorf("profile '%s' already exists at %s", name, profilePath)
 	}
+	return profilePath, nil
+}
 
-	profilePath := filepath.Join(profileDir, name+".env")
-	if _, err := os.Stat(profilePath); err == nil {
-		return fmt.Errorf("profile '%s' already exists at %s", name, profilePath)
+// writeNewProfile creates a profile with the given content, refusing to
+// overwrite an existing one.
+func writeNewProfile(name string, content []byte) (string, error) {
+	profilePath, err := newProfilePath(name)
+	if err != nil {
+		return "", err
+	}
+	lock, err := lockIndex(filepath.Dir(profilePath))
+	if err != nil {
+		return "", err
 	}
+	defer lock.release()
 
-	if err := os.WriteFile(profilePath, []byte(""), 0644); err != nil {
-		return fmt.Errorf("failed to create profile file: %v", err)
+	// O_EXCL catches a profile created by another process since the
+	// name was checked.
+	file, err := os.OpenFile(profilePath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, profileFileMode)
+	if err != nil {
+		if os.IsExist(err) {
+			return "", fmt.Errorf("profile '%s' already exists at %s", name, profilePath)
+		}
+		return "", fmt.Errorf("failed to create profile file: %v", err)
+	}
+	if _, err := file.Write(content); err != nil {
+		file.Close()
+		os.Remove(profilePath)
+		return "", fmt.Errorf("failed to create profile file: %v", err)
+	}
+	if err := file.Close(); err != nil {
+		return "", fmt.Errorf("failed to create profile file: %v", err)
+	}
+	return profilePath, nil
+}
+
+func CreateProfile(name string) error {
+	name = strings.TrimSpace(name)
+	profilePath, err := writeNewProfile(name, []byte(""))
+	if err != nil {
+		return err
 	}
 
 	model := CreateProfileModel{
@@ -3,7 +3,6 @@ package main
 import (
 	"fmt"
 	"os"
-	"os/user"
 	"path/filepath"
 	"strings"
 
@@ -36,7 +35,7 @@ func (m DeleteProfileModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			if !m.confirmed {
 				m.confirmed = true
 
-				if err := os.Remove(m.profilePath); err != nil {
+				if err := removeProfileFile(m.profilePath); err != nil {
 					m.err = fmt.Errorf("failed to delete profile: %v", err)
 					return m, tea.Quit
 				}
@@ -103,7 +102,7 @@ func (m DeleteProfileModel) View() string {
 	return "\n"
 }
 
-func DeleteProfile(name string) error {
+func DeleteProfile(name string, allowProtected bool) error {
 	if name == "" {
 		return fmt.Errorf("profile name cannot be empty")
 	}
@@ -113,35 +112,11 @@ func DeleteProfile(name string) error {
 		return fmt.Errorf("profile name cannot contain '/'")
 	}
 
-	currentUser, err := user.Current()
+	loc, err := editableProfile(name, allowProtected)
 	if err != nil {
-		return fmt.Errorf("failed to get current user: %v", err)
-	}
-
-	configPath := filepath.Join("/home", currentUser.Username, ".config", ProjectName, configFileName)
-	configContent, err := os.ReadFile(configPath)
-	if err != nil {
-		return fmt.Errorf("failed to read config file: %v", err)
-	}
-
-	var profileDir string
-	for _, line := range strings.Split(string(configContent), "\n") {
-		if strings.HasPrefix(line, "PROFILE_DIR=") {
-			profileDir = strings.TrimPrefix(line, "PROFILE_DIR=")
-			profileDir = strings.Split(profileDir, "#")[0]
-			profileDir = strings.TrimSpace(profileDir)
-			break
-		}
-	}
-
-	if profileDir == "" {
-		return fmt.Errorf("PROFILE_DIR not found in config")
-	}
-
-	profilePath := filepath.Join(profileDir, name+".env")
-	if _, err := os.Stat(profilePath); os.IsNotExist(err) {
-		return fmt.Errorf("profile '%s' does not exist", name)
+		return err
 	}
+	profilePath := loc.path
 
 	model := DeleteProfileModel{
 		profileName: name,
@@ -161,3 +136,24 @@ func DeleteProfile(name string) error {
 
 	return nil
 }
+
+// removeProfileFile deletes a profile once no other envman process is
+// writing it or changing the profiles in its directory. The lock file stays:
+// removing it while locked would let a waiting writer lock the unlinked file
+// while a new one locks a fresh file of the same name.
+func removeProfileFile(profilePath string) error {
+	index, err := lockIndex(filepath.Dir(profilePath))
+	if err != nil {
+		return err
+	}
+	defer index.release()
+	lock, err := lockProfile(profilePath)
+	if err != nil {
+		return err
+	}
+	defer lock.release()
+	if err := os.Remove(profilePath); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import "strings"
+
+type diffKind int
+
+const (
+	diffAdded diffKind = iota
+	diffRemoved
+	diffChanged
+)
+
+type keyDiff struct {
+	key      string
+	kind     diffKind
+	oldValue string
+	newValue string
+}
+
+// diffVariables compares two sets of variables by key. When a key occurs
+// more than once the last value wins, the same as when the profile is
+// loaded. Results follow the order of old, then keys only present in new.
+func diffVariables(old, new []profileVariable) []keyDiff {
+	oldValues := make(map[string]string)
+	var oldKeys []string
+	for _, v := range old {
+		if _, seen := oldValues[v.Key]; !seen {
+			oldKeys = append(oldKeys, v.Key)
+		}
+		oldValues[v.Key] = v.Value
+	}
+	newValues := make(map[string]string)
+	var newKeys []string
+	for _, v := range new {
+		if _, seen := newValues[v.Key]; !seen {
+			newKeys = append(newKeys, v.Key)
+		}
+		newValues[v.Key] = v.Value
+	}
+
+	var diffs []keyDiff
+	for _, key := range oldKeys {
+		newValue, ok := newValues[key]
+		switch {
+		case !ok:
+			diffs = append(diffs, keyDiff{key: key, kind: diffRemoved, oldValue: oldValues[key]})
+		case newValue != oldValues[key]:
+			diffs = append(diffs, keyDiff{key: key, kind: diffChanged, oldValue: oldValues[key], newValue: newValue})
+		}
+	}
+	for _, key := range newKeys {
+		if _, ok := oldValues[key]; !ok {
+			diffs = append(diffs, keyDiff{key: key, kind: diffAdded, newValue: newValues[key]})
+		}
+	}
+	return diffs
+}
+
+// maskValue hides a secret value. The mask has a fixed width so it does not
+// leak the length of the value.
+func maskValue(value string) string {
+	if value == "" {
+		return ""
+	}
+	return strings.Repeat("•", 8)
+}
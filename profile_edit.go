@@ -3,34 +3,68 @@ package main
 import (
 	"fmt"
 	"os"
-	"os/user"
 	"path/filepath"
+	"regexp"
 	"sort"
 	"strings"
 	"time"
 
+	"github.com/atotto/clipboard"
 	"github.com/gdamore/tcell/v2"
 	"github.com/rivo/tview"
 )
 
 type EditorConfig struct {
-	filePath       string
-	profileName    string
-	entries        int
-	lastMod        time.Time
-	sortBy         string
-	unsavedChanges bool
+	filePath        string
+	profileName     string
+	entries         int
+	lastMod         time.Time
+	sortBy          string
+	unsavedChanges  bool
+	backupRetention int
+	protected       bool
+	readOnly        string // why the profile cannot be saved, empty when it can
+	maskPatterns    []string
+	reviewSave      bool // show the changes for confirmation before saving
 }
 
 type Editor struct {
 	app        *tview.Application
 	config     EditorConfig
 	textArea   *tview.TextArea
+	table      *tview.Table
+	pages      *tview.Pages
 	header     *tview.TextView
 	status     *tview.TextView
 	messages   *tview.TextView
+	problems   *tview.TextView
+	position   *tview.TextView
+	editorPane *gutterFlex
 	lastBackup string
 	hasChanges bool
+	// diskContent and diskHash are what the profile held when it was last
+	// read or written, to notice changes made outside the editor.
+	diskContent string
+	diskHash    string
+	// recovered is the text last written to the recovery file, and
+	// recoveryPending is set while the user has not yet decided what to do
+	// with one left behind by an earlier session.
+	recovered       string
+	recoveryPending bool
+	// panel is the find or replace panel open under the text, if any, and
+	// search what it searches for, so every match can be highlighted.
+	panel  tview.Primitive
+	search *regexp.Regexp
+	// revealValues shows masked values in the table view.
+	revealValues bool
+	// diagnostics holds the problems found in the text by line.
+	diagnostics     map[int][]diagnostic
+	diagnosticCount int
+	diagTimer       *time.Timer
+	// modified marks the lines changed since the last save, and lineCount
+	// is the number of lines the gutter numbers.
+	modified  map[int]lineMark
+	lineCount int
 }
 
 func NewEditor(config EditorConfig) *Editor {
@@ -47,27 +81,80 @@ func (e *Editor) layout() *tview.Flex {
 
 	e.textArea = tview.NewTextArea().
 		SetPlaceholder("Enter your environment variables here...")
-	e.textArea.SetBorder(true).
+	// Ctrl+Q copies to the system clipboard as well; paste stays internal
+	// so it keeps working where no system clipboard is available.
+	var copied string
+	e.textArea.SetClipboard(func(text string) {
+		copied = text
+		if err := clipboard.WriteAll(text); err != nil {
+			tryAlternativeClipboard(text)
+		}
+	}, func() string {
+		return copied
+	})
+	// Lines do not wrap so that each row of the gutter belongs to one line.
+	e.textArea.SetWrap(false)
+	gutter := newEditorGutter(e)
+	e.editorPane = &gutterFlex{
+		Flex: tview.NewFlex().
+			AddItem(gutter, gutterWidth(0), 0, false).
+			AddItem(e.textArea, 0, 1, true),
+		gutter: gutter,
+	}
+	e.editorPane.SetBorder(true).
 		SetTitle(" Editor ").
 		SetTitleColor(tcell.ColorGreen).
 		SetTitleAlign(tview.AlignLeft)
 
+	e.table = tview.NewTable().
+		SetSelectable(true, true).
+		SetFixed(1, 0)
+	e.table.SetBorder(true).
+		SetTitle(" Table ").
+		SetTitleColor(tcell.ColorGreen).
+		SetTitleAlign(tview.AlignLeft)
+	e.pages = tview.NewPages().
+		AddPage("text", e.editorPane, true, true).
+		AddPage("table", e.table, true, false)
+
 	e.status = tview.NewTextView().
 		SetDynamicColors(true)
 	e.messages = tview.NewTextView().
 		SetDynamicColors(true)
+	e.problems = tview.NewTextView().
+		SetDynamicColors(true)
+	e.position = tview.NewTextView().
+		SetDynamicColors(true).
+		SetTextAlign(tview.AlignRight)
 
 	flex := tview.NewFlex().
-		SetDirection(tview.FlexRow).
+		SetDirection(tview.FlexRow)
+	if e.config.readOnly != "" {
+		banner := tview.NewTextView().
+			SetDynamicColors(true).
+			SetText(fmt.Sprintf("[black:yellow:b] READ-ONLY: %s — Ctrl+S saves a copy as a new profile ", e.config.readOnly))
+		flex.AddItem(banner, 1, 1, false)
+	}
+	if e.config.protected {
+		banner := tview.NewTextView().
+			SetDynamicColors(true).
+			SetText(fmt.Sprintf("[white:red:b] %s PROTECTED PROFILE: %s — edit with care ", iconWarning, e.config.profileName))
+		flex.AddItem(banner, 1, 1, false)
+	}
+	flex.
 		AddItem(e.header, 1, 1, false).
-		AddItem(e.textArea, 0, 1, true).
+		AddItem(e.pages, 0, 1, true).
+		AddItem(e.problems, 1, 1, false).
 		AddItem(e.messages, 1, 1, false).
-		AddItem(e.status, 1, 1, false)
+		AddItem(tview.NewFlex().
+			AddItem(e.status, 0, 1, false).
+			AddItem(e.position, 30, 0, false), 1, 1, false)
 
 	content, err := os.ReadFile(e.config.filePath)
 	if err != nil {
 		content = []byte("")
 	}
+	e.syncDisk(string(content))
 	lines := strings.Split(string(content), "\n")
 
 	if e.config.sortBy == "key" {
@@ -82,31 +169,69 @@ func (e *Editor) layout() *tview.Flex {
 	e.textArea.SetOffset(0, 0)
 	//e.textArea.SetText(string(content), false)
 	e.lastBackup = string(content)
-	e.updateStatus()
+	e.analyze()
+	e.updatePosition()
 
 	e.textArea.SetChangedFunc(func() {
 		e.hasChanges = true
 		e.updateStatus()
+		e.scheduleDiagnostics()
+	})
+	e.textArea.SetMovedFunc(func() {
+		e.updateProblems()
+		e.updatePosition()
+	})
+	e.table.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		return e.handleTableKey(flex, event)
 	})
 
 	e.app.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if e.config.readOnly != "" && event.Key() == tcell.KeyCtrlS {
+			e.showSaveAsDialog(flex)
+			return nil
+		}
+		if e.config.readOnly != "" && e.app.GetFocus() == e.textArea {
+			if isEditingKey(event) {
+				e.messages.SetText("[yellow::b]Read-only: " + e.config.readOnly)
+				return nil
+			}
+		}
+		if event.Key() == tcell.KeyCtrlT && (e.app.GetFocus() == e.textArea || e.app.GetFocus() == e.table) {
+			e.toggleTable()
+			return nil
+		}
+		if e.app.GetFocus() == e.textArea {
+			switch event.Key() {
+			case tcell.KeyCtrlF:
+				e.showFindBar(flex)
+				return nil
+			case tcell.KeyCtrlR:
+				// Not Ctrl+H: terminals send that for Backspace.
+				e.showReplacePanel(flex)
+				return nil
+			case tcell.KeyCtrlG:
+				e.showGoToKey(flex)
+				return nil
+			case tcell.KeyCtrlN:
+				e.nextProblem()
+				return nil
+			}
+		}
 		switch event.Key() {
 		case tcell.KeyCtrlS:
-			e.createBackup()
-			if err := saveContent(e.config.filePath, e.textArea.GetText()); err != nil {
-				e.messages.SetText("[red::b]Error saving: " + err.Error())
-			} else {
-				e.hasChanges = false
-				e.config.unsavedChanges = false
-				e.messages.SetText("[green::b]File saved successfully!")
-				e.updateStatus()
+			e.saveReviewed(flex, func() {
+				if isActiveProfile(e.config.profileName) {
+					e.messages.SetText("[green::b]File saved successfully![yellow] Run 'envman reload' to apply it to your shell.")
+				} else {
+					e.messages.SetText("[green::b]File saved successfully!")
+				}
 				go func() {
 					time.Sleep(2 * time.Second)
 					e.app.QueueUpdateDraw(func() {
 						e.messages.SetText("")
 					})
 				}()
-			}
+			})
 			return nil
 		case tcell.KeyCtrlO:
 			e.showSortDialog()
@@ -135,10 +260,9 @@ func (e *Editor) layout() *tview.Flex {
 					SetDoneFunc(func(buttonIndex int, buttonLabel string) {
 						switch buttonIndex {
 						case 0:
-							e.createBackup()
-							saveContent(e.config.filePath, e.textArea.GetText())
-							e.app.Stop()
+							e.saveReviewed(flex, e.app.Stop)
 						case 1:
+							e.hasChanges = false
 							e.app.Stop()
 						default:
 							e.app.SetRoot(flex, true)
@@ -166,10 +290,9 @@ func (e *Editor) updateStatus() {
 	}
 	e.config.unsavedChanges = e.hasChanges
 	e.config.entries = entries
-	duplicates := e.detectDuplicateKeys()
-	duplicatesText := ""
-	if len(duplicates) > 0 {
-		duplicatesText = "[red::b]Duplicates: " + strings.Join(duplicates, ", ")
+	problemsText := ""
+	if e.diagnosticCount > 0 {
+		problemsText = fmt.Sprintf("[red::b]Problems: %d", e.diagnosticCount)
 	}
 
 	unsavedText := ""
@@ -184,9 +307,17 @@ func (e *Editor) updateStatus() {
 		e.config.filePath,
 		e.config.sortBy,
 		unsavedText,
-		duplicatesText,
+		problemsText,
 	))
-	e.status.SetText(fmt.Sprintf("[yellow]Ctrl+S: Save | Ctrl+X: Quit | Ctrl+O: Sort | Ctrl+\\ : Comment/Uncomment"))
+	if e.tableMode() {
+		e.status.SetText("[yellow]Enter: Edit cell | a: Add | c: Duplicate | d: Delete | Shift+Up/Down: Move | v: Reveal | Ctrl+T: Text | Ctrl+S: Save")
+		return
+	}
+	if e.config.readOnly != "" {
+		e.status.SetText("[yellow]Ctrl+S: Save as new profile | Ctrl+Q: Copy selection | Ctrl+F: Find | Ctrl+G: Go to key | Ctrl+T: Table | Ctrl+X: Quit")
+		return
+	}
+	e.status.SetText(fmt.Sprintf("[yellow]Ctrl+S: Save | Ctrl+X: Quit | Ctrl+F: Find | Ctrl+R: Replace | Ctrl+G: Go to key | Ctrl+T: Table | Ctrl+O: Sort | Ctrl+\\: Comment"))
 }
 
 func (e *Editor) showSortDialog() {
@@ -220,15 +351,70 @@ func (e *Editor) showSortDialog() {
 	e.app.SetRoot(modal, false)
 }
 
+// save writes the buffer to the profile and only then rotates the backups,
+// so a failed write leaves both the profile and its backups untouched.
+func (e *Editor) save() error {
+	if e.config.readOnly != "" {
+		return fmt.Errorf("read-only: %s", e.config.readOnly)
+	}
+	text := e.textArea.GetText()
+	if err := saveContent(e.config.filePath, text); err != nil {
+		return err
+	}
+	e.syncDisk(text)
+	e.lastBackup = text
+	e.analyze()
+	return e.createBackup()
+}
+
 func (e *Editor) createBackup() error {
+	if e.config.backupRetention == 0 {
+		return nil
+	}
 	backupPath := e.config.filePath + ".bak"
+	rotateBackups(backupPath, e.config.backupRetention)
 	content := []byte(e.textArea.GetText())
-	err := os.WriteFile(backupPath, content, 0644)
-	if err != nil {
+	mode := existingMode(e.config.filePath)
+	if err := writeFileAtomic(backupPath, content, mode); err != nil {
 		return fmt.Errorf("failed to write backup file: %v", err)
 	}
-	return nil
+	return os.Chmod(backupPath, mode)
+}
+
+// backupSuffixPattern matches what a backup adds to its profile's file
+// name: .bak and .bak.N.
+var backupSuffixPattern = regexp.MustCompile(`^\.bak(\.[0-9]+)?$`)
+
+// profileBackups lists the backups of the profile at path by suffix, such
+// as .bak and .bak.2.
+func profileBackups(path string) []string {
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		return nil
+	}
+	var suffixes []string
+	for _, entry := range entries {
+		suffix, ok := strings.CutPrefix(entry.Name(), filepath.Base(path))
+		if ok && backupSuffixPattern.MatchString(suffix) {
+			suffixes = append(suffixes, suffix)
+		}
+	}
+	return suffixes
+}
+
+// rotateBackups shifts <profile>.bak to .bak.1, .bak.1 to .bak.2 and so on,
+// dropping the oldest so that at most retention backups remain.
+func rotateBackups(backupPath string, retention int) {
+	if retention <= 1 {
+		return
+	}
+	os.Remove(fmt.Sprintf("%s.%d", backupPath, retention-1))
+	for i := retention - 2; i >= 1; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", backupPath, i), fmt.Sprintf("%s.%d", backupPath, i+1))
+	}
+	os.Rename(backupPath, backupPath+".1")
 }
+
 func highlightLine(text string, cursorY int) string {
 	lines := strings.Split(text, "\n")
 	for i := range lines {
@@ -286,13 +472,73 @@ func (e *Editor) commentUncommentLine() {
 	e.updateStatus()
 }
 
+// isEditingKey reports whether event would change the text area. Cursor
+// movement, selection and Ctrl+Q (copy) are left alone in read-only mode.
+func isEditingKey(event *tcell.EventKey) bool {
+	switch event.Key() {
+	case tcell.KeyRune, tcell.KeyEnter, tcell.KeyTab, tcell.KeyBackspace, tcell.KeyBackspace2,
+		tcell.KeyDelete, tcell.KeyCtrlD, tcell.KeyCtrlK, tcell.KeyCtrlW, tcell.KeyCtrlU,
+		tcell.KeyCtrlV, tcell.KeyCtrlZ, tcell.KeyCtrlY, tcell.KeyCtrlO, tcell.KeyCtrlR, tcell.KeyCtrlBackslash:
+		return true
+	}
+	return false
+}
+
+// showSaveAsDialog asks for a profile name and writes the current text to
+// it. The editor then continues on the new, writable profile.
+func (e *Editor) showSaveAsDialog(flex *tview.Flex) {
+	form := tview.NewForm()
+	form.AddInputField("New profile name", e.config.profileName+"-copy", 30, nil, nil)
+	form.AddButton("Save", func() {
+		name := strings.TrimSpace(form.GetFormItem(0).(*tview.InputField).GetText())
+		profilePath, err := writeNewProfile(name, []byte(e.textArea.GetText()))
+		if err != nil {
+			e.app.SetRoot(flex, true)
+			e.messages.SetText("[red::b]Error saving: " + err.Error())
+			return
+		}
+		e.config.filePath = profilePath
+		e.config.profileName = name
+		e.config.readOnly = ""
+		e.config.protected = false
+		e.hasChanges = false
+		e.app.SetRoot(e.layout(), true)
+		e.messages.SetText("[green::b]Saved as new profile " + name)
+	})
+	form.AddButton("Cancel", func() {
+		e.app.SetRoot(flex, true)
+	})
+	form.SetBorder(true).SetTitle(" Save as new profile ").SetTitleAlign(tview.AlignLeft)
+	e.app.SetRoot(form, true)
+}
+
+// Run shows the editor until it is quit. Unsaved changes are autosaved to
+// a recovery file, which is kept only if the editor exits without saving
+// or explicitly discarding them.
 func (e *Editor) Run() error {
-	e.app.SetRoot(e.layout(), true).EnableMouse(true)
-	return e.app.Run()
+	flex := e.layout()
+	e.app.SetRoot(flex, true).EnableMouse(true)
+	if e.config.readOnly == "" {
+		e.offerRecovery(flex)
+	}
+
+	stop := make(chan struct{})
+	go e.autosave(stop)
+	err := e.app.Run()
+	close(stop)
+
+	if e.hasChanges {
+		e.writeRecovery()
+	} else if !e.recoveryPending {
+		e.removeRecovery()
+	}
+	return err
 }
 
+// saveContent atomically replaces a profile, keeping the mode and owner of
+// an existing file.
 func saveContent(filePath, content string) error {
-	return os.WriteFile(filePath, []byte(content), 0644)
+	return writeFileAtomic(filePath, []byte(content), profileFileMode)
 }
 
 func sortBy(lines []string, mode string) []string {
@@ -326,66 +572,34 @@ func sortBy(lines []string, mode string) []string {
 	return lines
 }
 
-func (e *Editor) detectDuplicateKeys() []string {
-	lines := strings.Split(e.textArea.GetText(), "\n")
-	keyMap := make(map[string][]int)
-	var duplicates []string
-
-	for i, line := range lines {
-		line = strings.TrimSpace(line)
-		if line == "" || strings.HasPrefix(line, "#") {
-			continue
-		}
-		parts := strings.SplitN(line, "=", 2)
-		if len(parts) > 0 {
-			key := strings.TrimSpace(parts[0])
-			keyMap[key] = append(keyMap[key], i+1)
-		}
-	}
-
-	for key, lineNumbers := range keyMap {
-		if len(lineNumbers) > 1 {
-			duplicates = append(duplicates, fmt.Sprintf("%s (%s)", key, strings.Join(strings.Split(strings.Trim(fmt.Sprint(lineNumbers), "[]"), " "), ", ")))
-		}
-	}
-
-	return duplicates
-}
-
-func EditProfile(name string) error {
+// EditProfile opens name in the editor. Protected profiles are only opened
+// with allowProtected, set by --allow-protected.
+func EditProfile(name string, allowProtected bool) error {
 	if name == "" {
 		return fmt.Errorf("profile name cannot be empty")
 	}
 
-	currentUser, err := user.Current()
+	cfg, err := loadConfig()
 	if err != nil {
-		return fmt.Errorf("failed to get current user: %v", err)
+		return err
 	}
-
-	configPath := filepath.Join("/home", currentUser.Username, ".config", ProjectName, configFileName)
-	configContent, err := os.ReadFile(configPath)
+	protected := isProtectedProfile(cfg, name)
+	loc, err := findProfile(name)
 	if err != nil {
-		return fmt.Errorf("failed to read config file: %v", err)
+		return err
 	}
 
-	var profileDir string
-	for _, line := range strings.Split(string(configContent), "\n") {
-		if strings.HasPrefix(line, "PROFILE_DIR=") {
-			profileDir = strings.TrimPrefix(line, "PROFILE_DIR=")
-			profileDir = strings.Split(profileDir, "#")[0]
-			profileDir = strings.TrimSpace(profileDir)
-			break
-		}
-	}
-
-	if profileDir == "" {
-		return fmt.Errorf("PROFILE_DIR not found in config")
+	readOnly := ""
+	if err := ensureWritable(loc); err != nil {
+		readOnly = err.Error()
+	} else if protected && !allowProtected {
+		return fmt.Errorf("profile '%s' is protected; run envman profile edit %s %s to change it", name, name, flagAllowProtected)
 	}
 
-	profilePath := filepath.Join(profileDir, name+".env")
+	profilePath := loc.path
 	fileInfo, err := os.Stat(profilePath)
-	if os.IsNotExist(err) {
-		return fmt.Errorf("profile '%s' does not exist", name)
+	if err != nil {
+		return fmt.Errorf("failed to stat profile: %v", err)
 	}
 
 	content, _ := os.ReadFile(profilePath)
@@ -397,17 +611,25 @@ func EditProfile(name string) error {
 	}
 
 	config := EditorConfig{
-		filePath:       profilePath,
-		profileName:    name,
-		entries:        entries,
-		lastMod:        fileInfo.ModTime(),
-		sortBy:         "none",
-		unsavedChanges: false,
+		filePath:        profilePath,
+		profileName:     name,
+		entries:         entries,
+		lastMod:         fileInfo.ModTime(),
+		sortBy:          "none",
+		unsavedChanges:  false,
+		backupRetention: cfg.BackupRetention,
+		maskPatterns:    cfg.MaskPatterns,
+		protected:       protected,
+		readOnly:        readOnly,
+		reviewSave:      reviewBeforeSave(cfg, name),
 	}
 
 	editor := NewEditor(config)
 	if err := editor.Run(); err != nil {
 		return fmt.Errorf("editor error: %v", err)
 	}
+	if containsString(changedProfiles(), name) {
+		fmt.Printf("%s Profile '%s' is loaded in this shell. Run 'envman reload' to apply your changes.\n", iconInfo, name)
+	}
 	return nil
 }
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+func TestReadOnlyEditorNeverSaves(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	path := filepath.Join(t.TempDir(), "shared.env")
+	if err := os.WriteFile(path, []byte("A=1\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	e := NewEditor(EditorConfig{
+		filePath:        path,
+		profileName:     "shared",
+		sortBy:          "none",
+		backupRetention: 1,
+		readOnly:        "shared is read-only",
+	})
+	flex := e.layout()
+	e.app.SetRoot(flex, true)
+	e.textArea.Replace(0, 0, "B=2\n")
+
+	ctrlS := tcell.NewEventKey(tcell.KeyCtrlS, 0, tcell.ModCtrl)
+	focusOutside := map[string]func(){
+		"table": func() {
+			e.toggleTable()
+		},
+		"find bar": func() {
+			e.showFindBar(flex)
+		},
+	}
+	for name, focus := range focusOutside {
+		e.app.SetRoot(flex, true)
+		focus()
+		if e.app.GetFocus() == e.textArea {
+			t.Fatalf("%s: focus is still on the text area", name)
+		}
+		e.app.GetInputCapture()(ctrlS)
+		if field, ok := e.app.GetFocus().(*tview.InputField); !ok || field.GetLabel() != "New profile name" {
+			t.Errorf("%s: Ctrl+S did not offer to save a copy", name)
+		}
+		e.closePanel(flex)
+	}
+
+	saved := false
+	e.saveChecked(flex, func() { saved = true })
+	if saved {
+		t.Error("saveChecked reported a save of a read-only profile")
+	}
+	if err := e.save(); err == nil {
+		t.Error("save wrote a read-only profile")
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "A=1\n" {
+		t.Errorf("profile changed to %q", content)
+	}
+	if _, err := os.Stat(path + ".bak"); !os.IsNotExist(err) {
+		t.Errorf("a backup was written next to the read-only profile")
+	}
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+)
+
+func loadProfileVariables(name string) ([]profileVariable, error) {
+	profilePath, err := resolveProfilePath(name)
+	if err != nil {
+		return nil, err
+	}
+	content, err := os.ReadFile(profilePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read profile: %v", err)
+	}
+	return parseProfileVariables(string(content)), nil
+}
+
+func shellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+func fishQuote(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	return "'" + strings.ReplaceAll(value, "'", `\'`) + "'"
+}
+
+// formatExports renders variables as statements that the given shell can
+// eval to set them in the current session. Keys that are not valid
+// variable names are left out, since the shell would evaluate them.
+func formatExports(vars []profileVariable, shell string) string {
+	var sb strings.Builder
+	for _, v := range vars {
+		if !envKeyPattern.MatchString(v.Key) {
+			continue
+		}
+		if shell == "fish" {
+			sb.WriteString(fmt.Sprintf("set -gx %s %s\n", v.Key, fishQuote(v.Value)))
+		} else {
+			sb.WriteString(fmt.Sprintf("export %s=%s\n", v.Key, shellQuote(v.Value)))
+		}
+	}
+	return sb.String()
+}
+
+// profileLayer records what one profile contributed when several are
+// applied on top of each other.
+type profileLayer struct {
+	name       string
+	keys       []string // every key the profile defines
+	added      []string
+	overridden map[string][]string // previous profile -> keys it lost
+}
+
+// mergeProfiles applies the named profiles left to right, later values
+// winning. Stacks expand to their members in place. The merged variables
+// keep the order in which keys first appear. Keys that are not valid
+// variable names are skipped with a warning on stderr. As when a profile is
+// sourced, $VAR and ${VAR} in values that are not single-quoted expand to
+// the value defined before them, or to the environment's.
+func mergeProfiles(names []string) ([]profileVariable, []profileLayer, error) {
+	names, err := expandStacks(names)
+	if err != nil {
+		return nil, nil, err
+	}
+	var merged []profileVariable
+	index := make(map[string]int)
+	owner := make(map[string]string)
+	layers := make([]profileLayer, 0, len(names))
+
+	for _, name := range names {
+		vars, err := loadProfileVariables(name)
+		if err != nil {
+			return nil, nil, err
+		}
+		layer := profileLayer{name: name, overridden: make(map[string][]string)}
+		for _, v := range vars {
+			if !envKeyPattern.MatchString(v.Key) {
+				fmt.Fprintf(os.Stderr, "%s%s%s Skipped:%s invalid key %q in %s\n",
+					colorYellow,
+					colorBold,
+					iconWarning,
+					colorReset,
+					v.Key,
+					name,
+				)
+				continue
+			}
+			if !v.literal {
+				v.Value = os.Expand(v.Value, func(ref string) string {
+					if i, ok := index[ref]; ok {
+						return merged[i].Value
+					}
+					return os.Getenv(ref)
+				})
+			}
+			if !containsString(layer.keys, v.Key) {
+				layer.keys = append(layer.keys, v.Key)
+			}
+			if i, ok := index[v.Key]; ok {
+				merged[i].Value = v.Value
+				if prev := owner[v.Key]; prev != name {
+					layer.overridden[prev] = append(layer.overridden[prev], v.Key)
+				}
+			} else {
+				index[v.Key] = len(merged)
+				merged = append(merged, v)
+				layer.added = append(layer.added, v.Key)
+			}
+			owner[v.Key] = name
+		}
+		layers = append(layers, layer)
+	}
+	return merged, layers, nil
+}
+
+// printLayerSummary reports on stderr which keys each profile added and
+// which earlier values it replaced.
+func printLayerSummary(layers []profileLayer) {
+	for _, layer := range layers {
+		added := "no new keys"
+		if len(layer.added) > 0 {
+			added = strings.Join(layer.added, ", ")
+		}
+		fmt.Fprintf(os.Stderr, "%s%s%s Loaded %s:%s %s\n",
+			colorGreen,
+			colorBold,
+			iconCheck,
+			layer.name,
+			colorReset,
+			added,
+		)
+
+		prevs := make([]string, 0, len(layer.overridden))
+		for prev := range layer.overridden {
+			prevs = append(prevs, prev)
+		}
+		sort.Strings(prevs)
+		for _, prev := range prevs {
+			fmt.Fprintf(os.Stderr, "  %soverrides %s:%s %s\n",
+				colorYellow,
+				prev,
+				colorReset,
+				strings.Join(layer.overridden[prev], ", "),
+			)
+		}
+	}
+}
+
+// ExportProfiles prints the merged variables of names as statements for
+// shell. forLoad is set by the shell load function: it also records the
+// profiles in ENVMAN_ACTIVE and prints the per-profile summary to stderr.
+func ExportProfiles(names []string, shell string, forLoad bool) error {
+	vars, layers, err := mergeProfiles(names)
+	if err != nil {
+		return err
+	}
+	if forLoad {
+		session, err := sessionVariables(vars, layers)
+		if err != nil {
+			return err
+		}
+		vars = append(vars, session...)
+	}
+	fmt.Print(formatExports(vars, shell))
+	if forLoad {
+		printLayerSummary(layers)
+	}
+	return nil
+}
+
+// profileEnv returns the current environment with the merged variables of
+// names and an updated ENVMAN_ACTIVE appended, ready for a child process.
+func profileEnv(names []string) ([]string, []profileLayer, error) {
+	vars, layers, err := mergeProfiles(names)
+	if err != nil {
+		return nil, nil, err
+	}
+	session, err := sessionVariables(vars, layers)
+	if err != nil {
+		return nil, nil, err
+	}
+	vars = append(vars, session...)
+
+	env := os.Environ()
+	for _, v := range vars {
+		if !envKeyPattern.MatchString(v.Key) {
+			continue
+		}
+		env = append(env, v.Key+"="+v.Value)
+	}
+	return env, layers, nil
+}
+
+// runAttached runs command on the current terminal. A non-zero exit is
+// returned as an *exec.ExitError.
+func runAttached(command []string, env []string) error {
+	cmd := exec.Command(command[0], command[1:]...)
+	cmd.Env = env
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return exitErr
+		}
+		return fmt.Errorf("failed to run %s: %v", command[0], err)
+	}
+	return nil
+}
+
+// ExecProfiles runs command with the merged variables of names added to
+// the current environment and exits with the command's exit code.
+func ExecProfiles(names []string, command []string) error {
+	if len(command) == 0 {
+		return fmt.Errorf("no command given")
+	}
+	env, _, err := profileEnv(names)
+	if err != nil {
+		return err
+	}
+	if err := runAttached(command, env); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			os.Exit(exitErr.ExitCode())
+		}
+		return err
+	}
+	return nil
+}
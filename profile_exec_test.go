@@ -0,0 +1,67 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseEnvLineStripsInlineComments(t *testing.T) {
+	tests := []struct {
+		line  string
+		value string
+	}{
+		{`B=value # comment`, "value"},
+		{`B="quoted value" # comment`, "quoted value"},
+		{`B='single' # comment`, "single"},
+		{`B="a # not a comment"`, "a # not a comment"},
+		{`URL=http://example.com/#anchor`, "http://example.com/#anchor"},
+		{`export B=value	# tab before comment`, "value"},
+	}
+	for _, tt := range tests {
+		_, value, ok := parseEnvLine(tt.line)
+		if !ok || value != tt.value {
+			t.Errorf("parseEnvLine(%q) = %q, %v; want %q", tt.line, value, ok, tt.value)
+		}
+	}
+}
+
+func TestMergeProfilesExpandsReferences(t *testing.T) {
+	dir := testHome(t)
+	home := os.Getenv("HOME")
+	t.Setenv("ENVMAN_TEST_OUTER", "outer")
+	profiles := map[string]string{
+		"base": "ROOT=/srv\n",
+		"app": `BIN=${HOME}/bin
+DATA="$ROOT/data" # from base
+LITERAL='${HOME}/bin'
+OUTER=${ENVMAN_TEST_OUTER}-x
+`,
+	}
+	for name, content := range profiles {
+		if err := os.WriteFile(filepath.Join(dir, name+".env"), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	vars, _, err := mergeProfiles([]string{"base", "app"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]string)
+	for _, v := range vars {
+		got[v.Key] = v.Value
+	}
+	want := map[string]string{
+		"ROOT":    "/srv",
+		"BIN":     home + "/bin",
+		"DATA":    "/srv/data",
+		"LITERAL": "${HOME}/bin",
+		"OUTER":   "outer-x",
+	}
+	for key, value := range want {
+		if got[key] != value {
+			t.Errorf("%s = %q, want %q", key, got[key], value)
+		}
+	}
+}
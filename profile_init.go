@@ -247,6 +247,9 @@ func tryAlternativeClipboard(content string) error {
 }
 
 func InitCommand(forShell bool, shell string) error {
+	if err := loadInitScripts(); err != nil {
+		return err
+	}
 
 	if forShell {
 
@@ -302,6 +305,9 @@ func getShellRC(shell string) string {
 }
 
 func detectShell() string {
+	if cfg, err := loadConfig(); err == nil && cfg.Shell != "" {
+		return cfg.Shell
+	}
 	shell := os.Getenv("SHELL")
 	switch {
 	case shell == "":
@@ -318,18 +324,23 @@ func detectShell() string {
 }
 
 func ensureEnvmanDirs() error {
-	currentUser, err := user.Current()
+	dataRoot, err := dataDir()
 	if err != nil {
-		return fmt.Errorf("failed to get current user: %v", err)
+		return err
+	}
+	profileDir, err := getEnvmanRoot()
+	if err != nil {
+		return err
 	}
 
 	dirs := []string{
-		filepath.Join("/home", currentUser.Username, ".envman"),
-		filepath.Join("/home", currentUser.Username, ".envman/completions"),
+		dataRoot,
+		filepath.Join(dataRoot, "completions"),
+		profileDir,
 	}
 
 	for _, dir := range dirs {
-		if err := os.MkdirAll(dir, 0755); err != nil {
+		if err := os.MkdirAll(dir, profileDirMode); err != nil {
 			return fmt.Errorf("failed to create directory %s: %v", dir, err)
 		}
 	}
@@ -337,11 +348,16 @@ func ensureEnvmanDirs() error {
 	return nil
 }
 
-func init() {
-	envmanRoot, err := getEnvmanRoot()
+// loadInitScripts renders the shell integration scripts. It runs after
+// EnsureConfig so that a first run can find the profile directory.
+func loadInitScripts() error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	envmanRoot, err := writeProfileDir(cfg)
 	if err != nil {
-		fmt.Println("Error getting envman root directory: ", err)
-		os.Exit(1)
+		return fmt.Errorf("failed to get envman root directory: %v", err)
 	}
 	bashInitScript = fmt.Sprintf(`export ENVMAN_ROOT="%s"
 
@@ -355,17 +371,25 @@ envman() {
     case "$command" in
     load)
         if [ "$#" -eq 0 ]; then
-            echo "Usage: envman load <profile>" >&2
-            return 1
+            local picked
+            picked="$(command envman pick load)" || return 1
+            set -- "$picked"
         fi
-        
-        local profile_path="$ENVMAN_ROOT/$1.env"
-        if [ -f "$profile_path" ]; then
-            source "$profile_path"
-            echo "Loaded profile: $1"
-        else
-            echo "Profile not found: $1" >&2
-            return 1
+
+        local exports
+        exports="$(command envman export --load --shell bash "$@")" || return 1
+        eval "$exports"
+        ;;
+    reload)
+        local exports
+        exports="$(command envman reload --shell bash)" || return 1
+        eval "$exports"
+        ;;
+    allow|deny)
+        command envman "$command" "$@" || return
+        if [ -n "${_ENVMAN_AUTOLOAD:-}" ]; then
+            _ENVMAN_LAST_PWD=""
+            _envman_hook
         fi
         ;;
     *)
@@ -373,7 +397,25 @@ envman() {
         ;;
     esac
 }
+
+# Prompt segment listing loaded profiles, protected ones in red. Add it with
+#   bash: PS1='$(envman_prompt)'"$PS1"
+#   zsh:  setopt PROMPT_SUBST; PROMPT='$(envman_prompt)'"$PROMPT"
+envman_prompt() {
+    [ -n "${ENVMAN_ACTIVE:-}" ] || return 0
+    if [ -n "${ZSH_VERSION:-}" ]; then
+        command envman status --prompt zsh 2>/dev/null
+    else
+        command envman status --prompt bash 2>/dev/null
+    fi
+}
 `, envmanRoot)
+	if cfg.Autoload {
+		bashInitScript += bashAutoloadHook
+	}
+	if cfg.ReloadHint {
+		bashInitScript += bashReloadHintHook
+	}
 	fishInitScript = fmt.Sprintf(`set -gx ENVMAN_ROOT "%s"
 
 function envman
@@ -383,21 +425,99 @@ function envman
     switch "$command"
     case "load"
         if test (count $argv) -eq 0
-            echo "Usage: envman load <profile>" >&2
-            return 1
+            set argv (command envman pick load); or return 1
         end
-        
-        set -l profile_path "$ENVMAN_ROOT/$argv[1].env"
-        if test -f "$profile_path"
-            source "$profile_path"
-            echo "Loaded profile: $argv[1]"
-        else
-            echo "Profile not found: $argv[1]" >&2
-            return 1
+
+        set -l exports (command envman export --load --shell fish $argv); or return 1
+        string join \n -- $exports | source
+    case "reload"
+        set -l exports (command envman reload --shell fish); or return 1
+        string join \n -- $exports | source
+    case allow deny
+        command envman "$command" $argv; or return
+        if functions -q __envman_hook
+            __envman_hook
         end
     case '*'
         command envman "$command" $argv
     end
 end
+
+# Prompt segment listing loaded profiles, protected ones in red. Call it
+# from fish_prompt: envman_prompt
+function envman_prompt
+    set -q ENVMAN_ACTIVE; or return 0
+    command envman status --prompt fish 2>/dev/null
+end
 `, envmanRoot)
+	if cfg.Autoload {
+		fishInitScript += fishAutoloadHook
+	}
+	if cfg.ReloadHint {
+		fishInitScript += fishReloadHintHook
+	}
+	return nil
+}
+
+// bashAutoloadHook runs envman hook whenever the working directory changes:
+// through chpwd in zsh and PROMPT_COMMAND in bash.
+const bashAutoloadHook = `
+_ENVMAN_AUTOLOAD=1
+_envman_hook() {
+    if [ "$PWD" != "${_ENVMAN_LAST_PWD:-}" ]; then
+        _ENVMAN_LAST_PWD="$PWD"
+        eval "$(command envman hook bash)"
+    fi
 }
+if [ -n "${ZSH_VERSION:-}" ]; then
+    autoload -Uz add-zsh-hook
+    add-zsh-hook chpwd _envman_hook
+else
+    case ";${PROMPT_COMMAND:-};" in
+    *";_envman_hook;"*) ;;
+    *) PROMPT_COMMAND="_envman_hook${PROMPT_COMMAND:+;$PROMPT_COMMAND}" ;;
+    esac
+fi
+_envman_hook
+`
+
+const fishAutoloadHook = `
+function __envman_hook --on-variable PWD
+    command envman hook fish | source
+end
+__envman_hook
+`
+
+// bashReloadHintHook checks the loaded profiles before each prompt and
+// repeats a reminder only when the set of changed profiles changes.
+const bashReloadHintHook = `
+_envman_reload_hint() {
+    [ -n "${ENVMAN_ACTIVE:-}" ] || return 0
+    local hint
+    hint="$(command envman status --check 2>/dev/null)"
+    if [ -n "$hint" ] && [ "$hint" != "${_ENVMAN_LAST_HINT:-}" ]; then
+        echo "$hint" >&2
+    fi
+    _ENVMAN_LAST_HINT="$hint"
+}
+if [ -n "${ZSH_VERSION:-}" ]; then
+    autoload -Uz add-zsh-hook
+    add-zsh-hook precmd _envman_reload_hint
+else
+    case ";${PROMPT_COMMAND:-};" in
+    *";_envman_reload_hint;"*) ;;
+    *) PROMPT_COMMAND="_envman_reload_hint${PROMPT_COMMAND:+;$PROMPT_COMMAND}" ;;
+    esac
+fi
+`
+
+const fishReloadHintHook = `
+function __envman_reload_hint --on-event fish_prompt
+    set -q ENVMAN_ACTIVE; or return 0
+    set -l hint (command envman status --check 2>/dev/null)
+    if test -n "$hint"; and test "$hint" != "$__envman_last_hint"
+        echo $hint >&2
+    end
+    set -g __envman_last_hint $hint
+end
+`
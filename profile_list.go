@@ -3,8 +3,8 @@ package main
 import (
 	"fmt"
 	"os"
-	"os/user"
 	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 
@@ -13,13 +13,19 @@ import (
 
 type ProfileInfo struct {
 	name         string
+	path         string
+	dir          string
+	shadowed     bool
 	size         int64
 	lastModified time.Time
 	entries      int
+	flags        []string
 }
 
 type ListProfileModel struct {
 	profiles []ProfileInfo
+	writeDir string
+	stacks   map[string][]string
 	err      error
 	done     bool
 }
@@ -66,33 +72,65 @@ func (m ListProfileModel) View() string {
 		len(m.profiles),
 	))
 
-	output.WriteString(fmt.Sprintf("%s%s%-20s %-8s %-19s %-19s\n",
+	output.WriteString(fmt.Sprintf("%s%s%-20s %-8s %-10s %-19s %s%s\n",
 		colorBold,
 		colorYellow,
 		"Profile Name",
 		"Entries",
+		"Size",
 		"Last Modified",
+		"Source",
 		colorReset,
 	))
 
 	output.WriteString(fmt.Sprintf("%s%s%s\n",
 		colorYellow,
-		strings.Repeat("-", 70),
+		strings.Repeat("-", 90),
 		colorReset,
 	))
 
 	for _, p := range m.profiles {
 		profileName := strings.TrimSuffix(p.name, ".env")
-		output.WriteString(fmt.Sprintf("%s %-20s %s%-8d%s %-19s\n",
+		source := displayPath(p.dir)
+		if p.shadowed {
+			source += fmt.Sprintf(" %s(shadowed)%s", colorRed, colorReset)
+		}
+		output.WriteString(fmt.Sprintf("%s %-20s %s%-8d%s %-10s %-19s %s\n",
 			colorBold,
 			profileName,
 			colorGreen,
 			p.entries,
 			colorReset,
+			formatSize(p.size),
 			p.lastModified.Format("2006-01-02 15:04"),
+			source,
+		))
+	}
+
+	if len(m.stacks) > 0 {
+		output.WriteString(fmt.Sprintf("\n%s %s%sStacks:%s (%d total)\n\n",
+			iconInfo,
+			colorGreen,
+			colorBold,
+			colorReset,
+			len(m.stacks),
 		))
+		names := make([]string, 0, len(m.stacks))
+		for name := range m.stacks {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			output.WriteString(fmt.Sprintf("%s %-20s%s %s\n",
+				colorBold,
+				name,
+				colorReset,
+				strings.Join(m.stacks[name], " + "),
+			))
+		}
 	}
 
+	output.WriteString(fmt.Sprintf("\n%s New profiles are written to %s\n", iconInfo, displayPath(m.writeDir)))
 	output.WriteString(fmt.Sprintf("\n%s %s%sCommands:%s\n",
 		iconInfo,
 		colorYellow,
@@ -122,67 +160,148 @@ func getProfileEntries(filePath string) int {
 	return count
 }
 
-func ListProfiles() error {
-	currentUser, err := user.Current()
-	if err != nil {
-		return fmt.Errorf("failed to get current user: %v", err)
+// profileFlags lists the flags output shows for the profile name in dir:
+// protected, readonly and shadowed, or project for a project profile.
+func profileFlags(cfg *Config, name, dir, writeDir string, shadowed bool) []string {
+	if strings.HasPrefix(name, projectProfilePrefix) {
+		return []string{"project"}
+	}
+	flags := []string{}
+	if isProtectedProfile(cfg, name) {
+		flags = append(flags, "protected")
 	}
+	if isReadOnlyProfile(cfg, name) || dir != writeDir {
+		flags = append(flags, "readonly")
+	}
+	if shadowed {
+		flags = append(flags, "shadowed")
+	}
+	return flags
+}
 
-	configPath := filepath.Join("/home", currentUser.Username, ".config", ProjectName, configFileName)
-	configContent, err := os.ReadFile(configPath)
+// collectProfiles lists the profiles of every directory on the search
+// path. A profile hidden by one of the same name earlier on the path is
+// kept but marked as shadowed.
+func collectProfiles() ([]ProfileInfo, error) {
+	cfg, err := loadConfig()
 	if err != nil {
-		return fmt.Errorf("failed to read config file: %v", err)
+		return nil, err
 	}
-
-	var profileDir string
-	for _, line := range strings.Split(string(configContent), "\n") {
-		if strings.HasPrefix(line, "PROFILE_DIR=") {
-			profileDir = strings.TrimPrefix(line, "PROFILE_DIR=")
-			profileDir = strings.Split(profileDir, "#")[0]
-			profileDir = strings.TrimSpace(profileDir)
-			break
-		}
+	dirs, err := profileSearchPath(cfg)
+	if err != nil {
+		return nil, err
 	}
-
-	if profileDir == "" {
-		return fmt.Errorf("PROFILE_DIR not found in config")
+	writeDir, err := writeProfileDir(cfg)
+	if err != nil {
+		return nil, err
 	}
 
-	if _, err := os.Stat(profileDir); os.IsNotExist(err) {
-		model := ListProfileModel{
-			profiles: []ProfileInfo{},
+	profiles := []ProfileInfo{}
+	seen := make(map[string]bool)
+	for _, profileDir := range dirs {
+		if _, err := os.Stat(profileDir); os.IsNotExist(err) {
+			continue
 		}
 
-		p := tea.NewProgram(model)
-		p.Run()
-		return nil
+		entries, err := os.ReadDir(profileDir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read profiles directory: %v", err)
+		}
+
+		for _, entry := range entries {
+			if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".env") {
+				info, err := entry.Info()
+				if err != nil {
+					continue
+				}
+				name := strings.TrimSuffix(entry.Name(), ".env")
+				fullPath := filepath.Join(profileDir, entry.Name())
+				entryCount := getProfileEntries(fullPath)
+				profiles = append(profiles, ProfileInfo{
+					name:         entry.Name(),
+					path:         fullPath,
+					dir:          profileDir,
+					shadowed:     seen[name],
+					size:         info.Size(),
+					lastModified: info.ModTime(),
+					entries:      entryCount,
+					flags:        profileFlags(cfg, name, profileDir, writeDir, seen[name]),
+				})
+				seen[name] = true
+			}
+		}
 	}
 
-	entries, err := os.ReadDir(profileDir)
+	project, err := findProject()
 	if err != nil {
-		return fmt.Errorf("failed to read profiles directory: %v", err)
+		return nil, err
 	}
-
-	var profiles []ProfileInfo
-	for _, entry := range entries {
-		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".env") {
+	if project != nil {
+		entries, err := os.ReadDir(project.profileDir)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to read project profiles directory: %v", err)
+		}
+		for _, entry := range entries {
+			if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".env") {
+				continue
+			}
 			info, err := entry.Info()
 			if err != nil {
 				continue
 			}
-			fullPath := filepath.Join(profileDir, entry.Name())
-			entryCount := getProfileEntries(fullPath)
+			fullPath := filepath.Join(project.profileDir, entry.Name())
 			profiles = append(profiles, ProfileInfo{
-				name:         entry.Name(),
+				name:         projectProfilePrefix + entry.Name(),
+				path:         fullPath,
+				dir:          project.profileDir,
 				size:         info.Size(),
 				lastModified: info.ModTime(),
-				entries:      entryCount,
+				entries:      getProfileEntries(fullPath),
+				flags:        profileFlags(cfg, projectProfilePrefix+strings.TrimSuffix(entry.Name(), ".env"), project.profileDir, writeDir, false),
 			})
 		}
 	}
+	return profiles, nil
+}
+
+// activeProfiles drops shadowed profiles, leaving one entry per name.
+func activeProfiles(profiles []ProfileInfo) []ProfileInfo {
+	active := make([]ProfileInfo, 0, len(profiles))
+	for _, p := range profiles {
+		if !p.shadowed {
+			active = append(active, p)
+		}
+	}
+	return active
+}
+
+func ListProfiles(format string) error {
+	if err := validateOutputFormat(format); err != nil {
+		return err
+	}
+
+	profiles, err := collectProfiles()
+	if err != nil {
+		return err
+	}
+
+	if format != "" && format != "table" {
+		return writeProfiles(os.Stdout, format, profiles)
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	writeDir, err := writeProfileDir(cfg)
+	if err != nil {
+		return err
+	}
 
 	model := ListProfileModel{
 		profiles: profiles,
+		writeDir: writeDir,
+		stacks:   cfg.Stacks,
 	}
 
 	p := tea.NewProgram(model)
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Output formats accepted by --output. An empty format means the default
+// interactive table.
+var outputFormats = []string{"table", "json", "yaml", "tsv", "names"}
+
+type profileRecord struct {
+	Name      string            `json:"name"`
+	Path      string            `json:"path"`
+	Source    string            `json:"source"`
+	Entries   int               `json:"entries"`
+	Size      int64             `json:"size"`
+	Modified  time.Time         `json:"modified"`
+	Flags     []string          `json:"flags"`
+	Variables []profileVariable `json:"variables,omitempty"`
+}
+
+type profileVariable struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+	// literal is set for single-quoted values, whose ${VAR} references are
+	// not expanded, and line is the profile line the variable was read
+	// from, if any.
+	literal bool
+	line    string
+}
+
+func validateOutputFormat(format string) error {
+	if format == "" {
+		return nil
+	}
+	for _, f := range outputFormats {
+		if f == format {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown output format '%s' (expected one of: %s)", format, strings.Join(outputFormats, ", "))
+}
+
+// parseOutputFlag pulls -o/--output out of args and returns the remaining
+// positional arguments.
+func parseOutputFlag(args []string) (string, []string, error) {
+	var format string
+	var rest []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "-o" || arg == "--output":
+			if i+1 >= len(args) {
+				return "", nil, fmt.Errorf("%s requires a value", arg)
+			}
+			format = args[i+1]
+			i++
+		case strings.HasPrefix(arg, "--output="):
+			format = strings.TrimPrefix(arg, "--output=")
+		default:
+			rest = append(rest, arg)
+		}
+	}
+	if err := validateOutputFormat(format); err != nil {
+		return "", nil, err
+	}
+	return format, rest, nil
+}
+
+func newProfileRecord(p ProfileInfo) profileRecord {
+	flags := p.flags
+	if flags == nil {
+		flags = []string{}
+	}
+	return profileRecord{
+		Name:     strings.TrimSuffix(p.name, ".env"),
+		Path:     p.path,
+		Source:   p.dir,
+		Entries:  p.entries,
+		Size:     p.size,
+		Modified: p.lastModified.Truncate(time.Second),
+		Flags:    flags,
+	}
+}
+
+func writeProfiles(w io.Writer, format string, profiles []ProfileInfo) error {
+	records := make([]profileRecord, 0, len(profiles))
+	for _, p := range profiles {
+		records = append(records, newProfileRecord(p))
+	}
+
+	switch format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(records)
+	case "yaml":
+		if len(records) == 0 {
+			_, err := fmt.Fprintln(w, "[]")
+			return err
+		}
+		for _, r := range records {
+			writeProfileYAML(w, r, "- ", "  ")
+		}
+		return nil
+	case "tsv":
+		for _, r := range records {
+			writeProfileTSV(w, r)
+		}
+		return nil
+	case "names":
+		// A shadowed profile's name already resolves to an earlier one.
+		for _, r := range records {
+			if !containsString(r.Flags, "shadowed") {
+				fmt.Fprintln(w, r.Name)
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("unsupported output format '%s'", format)
+}
+
+func writeProfileDetail(w io.Writer, format string, record profileRecord) error {
+	switch format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(record)
+	case "yaml":
+		writeProfileYAML(w, record, "", "")
+		return nil
+	case "tsv":
+		for _, v := range record.Variables {
+			fmt.Fprintf(w, "%s\t%s\n", v.Key, tsvEscape(v.Value))
+		}
+		return nil
+	case "names":
+		for _, v := range record.Variables {
+			fmt.Fprintln(w, v.Key)
+		}
+		return nil
+	}
+	return fmt.Errorf("unsupported output format '%s'", format)
+}
+
+// writeProfileYAML emits a record as a YAML mapping. Strings are written as
+// double-quoted scalars, which share their escaping rules with JSON.
+func writeProfileYAML(w io.Writer, r profileRecord, first, indent string) {
+	fmt.Fprintf(w, "%sname: %s\n", first, strconv.Quote(r.Name))
+	fmt.Fprintf(w, "%spath: %s\n", indent, strconv.Quote(r.Path))
+	fmt.Fprintf(w, "%ssource: %s\n", indent, strconv.Quote(r.Source))
+	fmt.Fprintf(w, "%sentries: %d\n", indent, r.Entries)
+	fmt.Fprintf(w, "%ssize: %d\n", indent, r.Size)
+	fmt.Fprintf(w, "%smodified: %s\n", indent, r.Modified.Format(time.RFC3339))
+	if len(r.Flags) == 0 {
+		fmt.Fprintf(w, "%sflags: []\n", indent)
+	} else {
+		fmt.Fprintf(w, "%sflags:\n", indent)
+		for _, f := range r.Flags {
+			fmt.Fprintf(w, "%s  - %s\n", indent, strconv.Quote(f))
+		}
+	}
+	if len(r.Variables) > 0 {
+		fmt.Fprintf(w, "%svariables:\n", indent)
+		for _, v := range r.Variables {
+			fmt.Fprintf(w, "%s  - key: %s\n", indent, strconv.Quote(v.Key))
+			fmt.Fprintf(w, "%s    value: %s\n", indent, strconv.Quote(v.Value))
+		}
+	}
+}
+
+// writeProfileTSV writes one line per profile with the columns
+// name, path, entries, size, modified (RFC 3339), comma-separated flags and
+// source directory.
+func writeProfileTSV(w io.Writer, r profileRecord) {
+	flags := "-"
+	if len(r.Flags) > 0 {
+		flags = strings.Join(r.Flags, ",")
+	}
+	fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%s\t%s\t%s\n",
+		r.Name,
+		r.Path,
+		r.Entries,
+		r.Size,
+		r.Modified.Format(time.RFC3339),
+		flags,
+		r.Source,
+	)
+}
+
+func tsvEscape(s string) string {
+	s = strings.ReplaceAll(s, "\\", "\\\\")
+	s = strings.ReplaceAll(s, "\t", "\\t")
+	return strings.ReplaceAll(s, "\n", "\\n")
+}
+
+func formatSize(size int64) string {
+	switch {
+	case size >= 1<<20:
+		return fmt.Sprintf("%.1f MiB", float64(size)/(1<<20))
+	case size >= 1<<10:
+		return fmt.Sprintf("%.1f KiB", float64(size)/(1<<10))
+	default:
+		return fmt.Sprintf("%d B", size)
+	}
+}
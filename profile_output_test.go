@@ -0,0 +1,32 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestWriteProfilesSkipsShadowedNames(t *testing.T) {
+	profiles := []ProfileInfo{
+		{name: "a.env", path: "/one/a.env", dir: "/one"},
+		{name: "b.env", path: "/one/b.env", dir: "/one"},
+		{name: "a.env", path: "/two/a.env", dir: "/two", flags: []string{"shadowed"}},
+	}
+
+	var names bytes.Buffer
+	if err := writeProfiles(&names, "names", profiles); err != nil {
+		t.Fatal(err)
+	}
+	if names.String() != "a\nb\n" {
+		t.Errorf("names = %q", names.String())
+	}
+
+	var tsv bytes.Buffer
+	if err := writeProfiles(&tsv, "tsv", profiles); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(tsv.String(), "\n"), "\n")
+	if len(lines) != 3 || strings.Split(lines[2], "\t")[5] != "shadowed" {
+		t.Errorf("tsv does not mark the shadowed profile:\n%s", tsv.String())
+	}
+}
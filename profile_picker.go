@@ -0,0 +1,252 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+const pickerVisibleRows = 10
+
+var errPickerCancelled = errors.New("no profile selected")
+
+type PickerModel struct {
+	action   string
+	profiles []ProfileInfo
+	filtered []ProfileInfo
+	query    string
+	cursor   int
+	offset   int
+	selected string
+	quitting bool
+}
+
+func NewPickerModel(action string, profiles []ProfileInfo) PickerModel {
+	m := PickerModel{
+		action:   action,
+		profiles: profiles,
+	}
+	m.filter()
+	return m
+}
+
+func (m PickerModel) Init() tea.Cmd {
+	return nil
+}
+
+func (m PickerModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch msg.Type {
+		case tea.KeyCtrlC, tea.KeyEsc:
+			m.quitting = true
+			return m, tea.Quit
+		case tea.KeyEnter:
+			if len(m.filtered) > 0 {
+				m.selected = strings.TrimSuffix(m.filtered[m.cursor].name, ".env")
+			}
+			m.quitting = true
+			return m, tea.Quit
+		case tea.KeyUp, tea.KeyCtrlP, tea.KeyShiftTab:
+			m.move(-1)
+		case tea.KeyDown, tea.KeyCtrlN, tea.KeyTab:
+			m.move(1)
+		case tea.KeyPgUp:
+			m.move(-pickerVisibleRows)
+		case tea.KeyPgDown:
+			m.move(pickerVisibleRows)
+		case tea.KeyBackspace:
+			if len(m.query) > 0 {
+				runes := []rune(m.query)
+				m.query = string(runes[:len(runes)-1])
+				m.filter()
+			}
+		case tea.KeyCtrlU:
+			m.query = ""
+			m.filter()
+		case tea.KeyRunes, tea.KeySpace:
+			m.query += string(msg.Runes)
+			m.filter()
+		}
+	}
+	return m, nil
+}
+
+func (m *PickerModel) move(delta int) {
+	if len(m.filtered) == 0 {
+		return
+	}
+	m.cursor += delta
+	if m.cursor < 0 {
+		m.cursor = 0
+	}
+	if m.cursor >= len(m.filtered) {
+		m.cursor = len(m.filtered) - 1
+	}
+	if m.cursor < m.offset {
+		m.offset = m.cursor
+	}
+	if m.cursor >= m.offset+pickerVisibleRows {
+		m.offset = m.cursor - pickerVisibleRows + 1
+	}
+}
+
+// filter narrows the profile list to fuzzy matches of the query, best
+// matches first. An empty query keeps the original order.
+func (m *PickerModel) filter() {
+	m.cursor = 0
+	m.offset = 0
+	if m.query == "" {
+		m.filtered = m.profiles
+		return
+	}
+
+	type scored struct {
+		profile ProfileInfo
+		score   int
+	}
+	var matches []scored
+	for _, p := range m.profiles {
+		if score, ok := fuzzyScore(strings.TrimSuffix(p.name, ".env"), m.query); ok {
+			matches = append(matches, scored{p, score})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].score > matches[j].score
+	})
+
+	m.filtered = make([]ProfileInfo, 0, len(matches))
+	for _, s := range matches {
+		m.filtered = append(m.filtered, s.profile)
+	}
+}
+
+// fuzzyScore reports whether every rune of query appears in candidate in
+// order (case-insensitive). Consecutive runs and matches at word starts
+// score higher.
+func fuzzyScore(candidate, query string) (int, bool) {
+	c := []rune(strings.ToLower(candidate))
+	q := []rune(strings.ToLower(query))
+	score := 0
+	ci := 0
+	prev := -2
+	for _, r := range q {
+		found := false
+		for ; ci < len(c); ci++ {
+			if c[ci] != r {
+				continue
+			}
+			score++
+			if ci == prev+1 {
+				score += 3
+			}
+			if ci == 0 || !unicode.IsLetter(c[ci-1]) && !unicode.IsDigit(c[ci-1]) {
+				score += 2
+			}
+			prev = ci
+			ci++
+			found = true
+			break
+		}
+		if !found {
+			return 0, false
+		}
+	}
+	return score - len(c)/4, true
+}
+
+func (m PickerModel) View() string {
+	if m.quitting {
+		return ""
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("%s %s%sSelect a profile to %s%s\n",
+		iconInfo,
+		colorGreen,
+		colorBold,
+		m.action,
+		colorReset,
+	))
+	sb.WriteString(fmt.Sprintf("%s>%s %s%s█%s\n\n", colorYellow, colorReset, m.query, colorBold, colorReset))
+
+	if len(m.filtered) == 0 {
+		sb.WriteString(fmt.Sprintf("  %sNo matching profiles%s\n", colorYellow, colorReset))
+	}
+
+	end := m.offset + pickerVisibleRows
+	if end > len(m.filtered) {
+		end = len(m.filtered)
+	}
+	for i := m.offset; i < end; i++ {
+		p := m.filtered[i]
+		cursor := "  "
+		name := fmt.Sprintf("%-24s", strings.TrimSuffix(p.name, ".env"))
+		if i == m.cursor {
+			cursor = colorGreen + "❯ " + colorReset
+			name = colorBold + name + colorReset
+		}
+		sb.WriteString(fmt.Sprintf("%s%s %s%3d entries%s  %s\n",
+			cursor,
+			name,
+			colorGreen,
+			p.entries,
+			colorReset,
+			p.lastModified.Format("2006-01-02 15:04"),
+		))
+	}
+
+	sb.WriteString(fmt.Sprintf("\n%s%d/%d%s  %s↑↓%s move  %senter%s select  %sesc%s cancel\n",
+		colorYellow,
+		len(m.filtered),
+		len(m.profiles),
+		colorReset,
+		colorBold,
+		colorReset,
+		colorBold,
+		colorReset,
+		colorBold,
+		colorReset,
+	))
+	return sb.String()
+}
+
+// PickProfile shows the fuzzy profile picker and returns the chosen name.
+// The UI is drawn on stderr so the result can be captured from stdout by
+// the shell integration.
+func PickProfile(action string) (string, error) {
+	profiles, err := collectProfiles()
+	if err != nil {
+		return "", err
+	}
+	profiles = activeProfiles(profiles)
+	if len(profiles) == 0 {
+		return "", fmt.Errorf("no profiles found, create one with: envman profile create <name>")
+	}
+
+	p := tea.NewProgram(NewPickerModel(action, profiles), tea.WithOutput(os.Stderr))
+	finalModel, err := p.Run()
+	if err != nil {
+		return "", fmt.Errorf("failed to run picker: %v", err)
+	}
+
+	m, ok := finalModel.(PickerModel)
+	if !ok || m.selected == "" {
+		return "", errPickerCancelled
+	}
+	return m.selected, nil
+}
+
+// pickProfileIfEmpty returns name unchanged unless it is empty, in which
+// case the user is asked to pick a profile for action.
+func pickProfileIfEmpty(name, action string) (string, error) {
+	if strings.TrimSpace(name) != "" {
+		return name, nil
+	}
+	return PickProfile(action)
+}
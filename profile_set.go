@@ -0,0 +1,310 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+)
+
+var envKeyPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// formatEnvLine renders KEY=value with value taken literally, as given
+// on the command line.
+func formatEnvLine(key, value string) string {
+	return formatEnvValue(key, value, true)
+}
+
+// formatEnvValue renders KEY=value, quoting values that parseEnvLine would
+// otherwise split or cut short. Unless literal is set, $VAR references in
+// value are meant to expand, so it is not single-quoted. Values that
+// checkEnvValue rejects cannot be rendered faithfully.
+func formatEnvValue(key, value string, literal bool) string {
+	if value == "" || !strings.ContainsAny(value, " \t#\"'$`\\") {
+		return key + "=" + value
+	}
+	if !strings.ContainsAny(value, "\"`") && (!literal || !strings.Contains(value, "$")) {
+		return key + `="` + value + `"`
+	}
+	if literal && !strings.Contains(value, "'") {
+		return key + "='" + value + "'"
+	}
+	return key + "=" + value
+}
+
+// checkEnvValue reports whether formatEnvValue can write value on one line
+// that reads back as exactly value, expanding or literal as requested.
+func checkEnvValue(key, value string, literal bool) error {
+	if strings.ContainsAny(value, "\r\n") {
+		return fmt.Errorf("the value of %s contains a line break, which a .env line cannot hold", key)
+	}
+	k, v, readLiteral, ok := parseEnvEntry(formatEnvValue(key, value, literal))
+	if !ok || k != key || v != value || strings.Contains(v, "$") && readLiteral != literal {
+		return fmt.Errorf("the value of %s cannot be written on a single .env line: it mixes quotes with spaces, # or $", key)
+	}
+	return nil
+}
+
+// splitLines splits file content into lines without a phantom empty line
+// for the trailing newline or an empty file.
+func splitLines(content string) []string {
+	if content == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+}
+
+func joinLines(lines []string) string {
+	if len(lines) == 0 {
+		return ""
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// setProfileValues updates content in place: the first line defining each
+// key gets the new value, later duplicates are dropped and keys that were
+// not present are appended. Comments and the order of other lines are kept.
+// A variable read from another profile keeps its line as written there.
+func setProfileValues(content string, vars []profileVariable) (string, []string, []string) {
+	values := make(map[string]profileVariable)
+	var order []string
+	for _, v := range vars {
+		if _, ok := values[v.Key]; !ok {
+			order = append(order, v.Key)
+		}
+		values[v.Key] = v
+	}
+	render := func(v profileVariable, prefix string) string {
+		if v.line != "" {
+			return v.line
+		}
+		return prefix + formatEnvValue(v.Key, v.Value, v.literal)
+	}
+
+	var lines, updated []string
+	written := make(map[string]bool)
+	for _, line := range splitLines(content) {
+		key, _, ok := parseEnvLine(line)
+		v, replace := values[key]
+		if !ok || !replace {
+			lines = append(lines, line)
+			continue
+		}
+		if written[key] {
+			continue
+		}
+		written[key] = true
+		updated = append(updated, key)
+		prefix := ""
+		if strings.HasPrefix(strings.TrimSpace(line), "export ") {
+			prefix = "export "
+		}
+		lines = append(lines, render(v, prefix))
+	}
+
+	var added []string
+	for _, key := range order {
+		if !written[key] {
+			added = append(added, key)
+			lines = append(lines, render(values[key], ""))
+		}
+	}
+	return joinLines(lines), updated, added
+}
+
+// unsetProfileKeys removes every line defining one of keys.
+func unsetProfileKeys(content string, keys []string) (string, []string) {
+	var lines, removed []string
+	for _, line := range splitLines(content) {
+		if key, _, ok := parseEnvLine(line); ok && containsString(keys, key) {
+			if !containsString(removed, key) {
+				removed = append(removed, key)
+			}
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return joinLines(lines), removed
+}
+
+// editableProfile resolves name for a command that rewrites it. Read-only
+// profiles are refused, and protected ones need --allow-protected.
+func editableProfile(name string, allowProtected bool) (profileLocation, error) {
+	loc, err := findProfile(name)
+	if err != nil {
+		return profileLocation{}, err
+	}
+	if err := ensureWritable(loc); err != nil {
+		return profileLocation{}, err
+	}
+	cfg, err := loadConfig()
+	if err != nil {
+		return profileLocation{}, err
+	}
+	if isProtectedProfile(cfg, name) && !allowProtected {
+		return profileLocation{}, fmt.Errorf("profile '%s' is protected; pass %s to change it", name, flagAllowProtected)
+	}
+	return loc, nil
+}
+
+func parseAssignments(args []string) ([]profileVariable, error) {
+	var vars []profileVariable
+	for _, arg := range args {
+		parts := strings.SplitN(arg, "=", 2)
+		if len(parts) != 2 || !envKeyPattern.MatchString(parts[0]) {
+			return nil, fmt.Errorf("invalid assignment '%s' (expected KEY=value)", arg)
+		}
+		// The shell has already expanded the arguments.
+		if err := checkEnvValue(parts[0], parts[1], true); err != nil {
+			return nil, err
+		}
+		vars = append(vars, profileVariable{Key: parts[0], Value: parts[1], literal: true})
+	}
+	return vars, nil
+}
+
+func printChanges(label string, keys []string) {
+	if len(keys) == 0 {
+		return
+	}
+	fmt.Printf("%s%s%s %s:%s %s\n",
+		colorGreen,
+		colorBold,
+		iconCheck,
+		label,
+		colorReset,
+		strings.Join(keys, ", "),
+	)
+}
+
+// SetVariables sets KEY=value assignments in a profile.
+func SetVariables(name string, assignments []string, allowProtected bool) error {
+	vars, err := parseAssignments(assignments)
+	if err != nil {
+		return err
+	}
+	loc, err := editableProfile(name, allowProtected)
+	if err != nil {
+		return err
+	}
+	var updated, added []string
+	err = updateProfile(loc.path, func(content string) (string, error) {
+		var updatedContent string
+		updatedContent, updated, added = setProfileValues(content, vars)
+		return updatedContent, nil
+	})
+	if err != nil {
+		return err
+	}
+	printChanges("Updated", updated)
+	printChanges("Added", added)
+	return nil
+}
+
+// UnsetVariables removes keys from a profile.
+func UnsetVariables(name string, keys []string, allowProtected bool) error {
+	loc, err := editableProfile(name, allowProtected)
+	if err != nil {
+		return err
+	}
+	var removed []string
+	err = updateProfile(loc.path, func(content string) (string, error) {
+		var updatedContent string
+		updatedContent, removed = unsetProfileKeys(content, keys)
+		if len(removed) == 0 {
+			return "", fmt.Errorf("none of %s are set in '%s'", strings.Join(keys, ", "), name)
+		}
+		return updatedContent, nil
+	})
+	if err != nil {
+		return err
+	}
+	printChanges("Removed", removed)
+	return nil
+}
+
+// ImportProfile reads a .env file ("-" for stdin) into a profile. A new
+// profile takes the file as is; an existing one has the file's keys merged
+// in.
+func ImportProfile(name, source string, allowProtected bool) error {
+	var content []byte
+	var err error
+	if source == "-" {
+		content, err = io.ReadAll(os.Stdin)
+	} else {
+		content, err = os.ReadFile(source)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %v", source, err)
+	}
+
+	if _, err := findProfile(name); err != nil {
+		profilePath, err := writeNewProfile(name, content)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s%s%s Imported:%s %s (%d entries)\n",
+			colorGreen,
+			colorBold,
+			iconCheck,
+			colorReset,
+			profilePath,
+			len(parseProfileVariables(string(content))),
+		)
+		return nil
+	}
+
+	loc, err := editableProfile(name, allowProtected)
+	if err != nil {
+		return err
+	}
+	// The file's lines are copied as written, so values keep their quoting
+	// and whether they expand.
+	vars := parseProfileVariables(string(content))
+	var updated, added []string
+	err = updateProfile(loc.path, func(existing string) (string, error) {
+		var updatedContent string
+		updatedContent, updated, added = setProfileValues(existing, vars)
+		return updatedContent, nil
+	})
+	if err != nil {
+		return err
+	}
+	printChanges("Updated", updated)
+	printChanges("Added", added)
+	return nil
+}
+
+// SetReadOnly adds name to or removes it from readonly_profiles.
+func SetReadOnly(name string, readOnly bool) error {
+	if readOnly {
+		if _, err := findProfile(name); err != nil {
+			return err
+		}
+	}
+	changed, err := updateProfileList("readonly_profiles", name, readOnly)
+	if err != nil {
+		return err
+	}
+	switch {
+	case !changed && readOnly:
+		return fmt.Errorf("profile '%s' is already read-only", name)
+	case !changed:
+		return fmt.Errorf("profile '%s' is not marked read-only", name)
+	}
+
+	state := "Read-only"
+	if !readOnly {
+		state = "Writable"
+	}
+	fmt.Printf("%s%s%s %s:%s %s\n",
+		colorGreen,
+		colorBold,
+		iconCheck,
+		state,
+		colorReset,
+		name,
+	)
+	return nil
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSetProfileValuesKeepsImportedLines(t *testing.T) {
+	existing := "A=1\nC=old\n"
+	imported := parseProfileVariables("C=${A}-suffix\nD='$literal' # kept\nexport E=\"$A and more\"\n")
+	content, updated, added := setProfileValues(existing, imported)
+
+	want := "A=1\nC=${A}-suffix\nD='$literal' # kept\nexport E=\"$A and more\"\n"
+	if content != want {
+		t.Errorf("content = %q, want %q", content, want)
+	}
+	if strings.Join(updated, ",") != "C" || strings.Join(added, ",") != "D,E" {
+		t.Errorf("updated %v, added %v", updated, added)
+	}
+	for _, v := range parseProfileVariables(content) {
+		if v.Key == "C" && v.literal {
+			t.Errorf("C no longer expands: %q", v.line)
+		}
+	}
+}
+
+func TestFormatEnvValue(t *testing.T) {
+	tests := []struct {
+		value   string
+		literal bool
+		line    string
+	}{
+		{"plain", true, "K=plain"},
+		{"a b", true, `K="a b"`},
+		{"$5 off", true, "K='$5 off'"},
+		{"${A}-x", false, `K="${A}-x"`},
+		{"$A and more", false, `K="$A and more"`},
+		{`say "hi"`, true, `K='say "hi"'`},
+	}
+	for _, tt := range tests {
+		line := formatEnvValue("K", tt.value, tt.literal)
+		if line != tt.line {
+			t.Errorf("formatEnvValue(%q, %v) = %q, want %q", tt.value, tt.literal, line, tt.line)
+		}
+		if err := checkEnvValue("K", tt.value, tt.literal); err != nil {
+			t.Errorf("checkEnvValue(%q, %v): %v", tt.value, tt.literal, err)
+		}
+	}
+
+	for _, value := range []string{"a\nb", `it's "$HOME"`} {
+		if err := checkEnvValue("K", value, true); err == nil {
+			t.Errorf("checkEnvValue(%q) accepted a value it cannot write", value)
+		}
+	}
+}
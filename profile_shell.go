@@ -0,0 +1,164 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// envShell is set inside envman shell to the profiles it was started with.
+const envShell = "ENVMAN_SHELL"
+
+// shellBinary finds the executable for shell, preferring $SHELL when it is
+// the same shell.
+func shellBinary(shell string) (string, error) {
+	if current := os.Getenv("SHELL"); current != "" && filepath.Base(current) == shell {
+		return current, nil
+	}
+	path, err := exec.LookPath(shell)
+	if err != nil {
+		return "", fmt.Errorf("%s not found in PATH", shell)
+	}
+	return path, nil
+}
+
+// shellPromptPrefix is the label prepended to the subshell prompt, in red
+// when any of the profiles is protected.
+func shellPromptPrefix(label string, protected bool) (text, start, end string) {
+	text = "(envman:" + label + ")"
+	if protected && colorRed != "" {
+		start = colorRed + colorBold
+		end = colorReset
+	}
+	return text, start, end
+}
+
+// shellCommand builds the command line that starts shell with the prompt
+// prefixed by label. Startup files go in tmpDir.
+func shellCommand(shell, binary, label string, protected bool, tmpDir string) ([]string, []string, error) {
+	text, start, end := shellPromptPrefix(label, protected)
+
+	switch shell {
+	case "bash":
+		prefix := text
+		if start != "" {
+			prefix = `\[` + start + `\]` + text + `\[` + end + `\]`
+		}
+		rc := fmt.Sprintf(`[ -f "$HOME/.bashrc" ] && . "$HOME/.bashrc"
+PS1=%s"$PS1"
+`, shellQuote(prefix+" "))
+		rcPath := filepath.Join(tmpDir, "bashrc")
+		if err := os.WriteFile(rcPath, []byte(rc), 0600); err != nil {
+			return nil, nil, fmt.Errorf("failed to write shell startup file: %v", err)
+		}
+		return []string{binary, "--rcfile", rcPath, "-i"}, nil, nil
+	case "zsh":
+		prefix := text
+		if start != "" {
+			prefix = "%{" + start + "%}" + text + "%{" + end + "%}"
+		}
+		original := os.Getenv("ZDOTDIR")
+		if original == "" {
+			home, err := homeDir()
+			if err != nil {
+				return nil, nil, err
+			}
+			original = home
+		}
+		files := map[string]string{
+			".zshenv": `[ -f "$ENVMAN_ZDOTDIR/.zshenv" ] && . "$ENVMAN_ZDOTDIR/.zshenv"
+`,
+			".zshrc": fmt.Sprintf(`ZDOTDIR="$ENVMAN_ZDOTDIR"
+unset ENVMAN_ZDOTDIR
+[ -f "$ZDOTDIR/.zshrc" ] && . "$ZDOTDIR/.zshrc"
+PROMPT=%s"$PROMPT"
+`, shellQuote(prefix+" ")),
+		}
+		for name, content := range files {
+			if err := os.WriteFile(filepath.Join(tmpDir, name), []byte(content), 0600); err != nil {
+				return nil, nil, fmt.Errorf("failed to write shell startup file: %v", err)
+			}
+		}
+		return []string{binary, "-i"}, []string{"ZDOTDIR=" + tmpDir, "ENVMAN_ZDOTDIR=" + original}, nil
+	case "fish":
+		init := fmt.Sprintf(`functions -c fish_prompt __envman_shell_prompt
+function fish_prompt
+    printf '%%s%%s%%s ' %s %s %s
+    __envman_shell_prompt
+end
+`, fishQuote(start), fishQuote(text), fishQuote(end))
+		return []string{binary, "-i", "-C", init}, nil, nil
+	}
+	return nil, nil, fmt.Errorf("unsupported shell: %s", shell)
+}
+
+// SubShell starts the user's shell as a child process with the profiles
+// applied. Nothing it sets reaches the parent shell, so leaving it returns
+// to a clean environment.
+func SubShell(names []string) error {
+	env, layers, err := profileEnv(names)
+	if err != nil {
+		return err
+	}
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	label := strings.Join(names, "+")
+	protected := false
+	for _, layer := range layers {
+		if isProtectedProfile(cfg, layer.name) {
+			protected = true
+		}
+	}
+
+	shell := detectShell()
+	binary, err := shellBinary(shell)
+	if err != nil {
+		return err
+	}
+	tmpDir, err := os.MkdirTemp("", "envman-shell-")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	command, extraEnv, err := shellCommand(shell, binary, label, protected, tmpDir)
+	if err != nil {
+		return err
+	}
+	env = append(env, extraEnv...)
+	env = append(env, envShell+"="+label)
+
+	if outer := os.Getenv(envShell); outer != "" {
+		fmt.Fprintf(os.Stderr, "%s%s%s Nested:%s already inside envman shell for %s\n",
+			colorYellow,
+			colorBold,
+			iconWarning,
+			colorReset,
+			outer,
+		)
+	}
+	fmt.Fprintf(os.Stderr, "%s%s%s Starting %s with %s.%s Type 'exit' to return; nothing is kept in the parent shell.\n",
+		colorGreen,
+		colorBold,
+		iconCheck,
+		shell,
+		label,
+		colorReset,
+	)
+
+	err = runAttached(command, env)
+	fmt.Fprintf(os.Stderr, "%s Left envman shell for %s\n", iconInfo, label)
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		os.RemoveAll(tmpDir)
+		os.Exit(exitErr.ExitCode())
+	}
+	return err
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// Profiles hold secrets, so they and the directories holding them are only
+// accessible to their owner.
+const (
+	profileFileMode os.FileMode = 0600
+	profileDirMode  os.FileMode = 0700
+)
+
+// existingMode returns the permission bits of path, or profileFileMode when
+// it does not exist yet.
+func existingMode(path string) os.FileMode {
+	if info, err := os.Stat(path); err == nil {
+		return info.Mode().Perm()
+	}
+	return profileFileMode
+}
+
+// writeFileAtomic replaces path with content without ever leaving a
+// truncated file behind: the data goes to a temporary file in the same
+// directory, is synced, and is then renamed over path. The mode and owner of
+// an existing file are kept; a new file gets mode.
+func writeFileAtomic(path string, content []byte, mode os.FileMode) error {
+	info, statErr := os.Stat(path)
+	if statErr == nil {
+		mode = info.Mode().Perm()
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath)
+
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(mode); err != nil {
+		tmp.Close()
+		return err
+	}
+	if statErr == nil {
+		if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+			// Only root can give a file away; for everyone else the owner is
+			// already right, so a failure here is not worth aborting over.
+			tmp.Chown(int(stat.Uid), int(stat.Gid))
+		}
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return err
+	}
+
+	// Make the rename itself durable.
+	if dir, err := os.Open(filepath.Dir(path)); err == nil {
+		dir.Sync()
+		dir.Close()
+	}
+	return nil
+}
+
+// profileLocation is where a profile name resolved to on the search path.
+type profileLocation struct {
+	name string
+	path string
+	dir  string
+}
+
+// profileSearchPath returns the expanded profile directories in precedence
+// order. When a name exists in several directories the first one wins.
+func profileSearchPath(cfg *Config) ([]string, error) {
+	var dirs []string
+	seen := make(map[string]bool)
+	for _, dir := range cfg.ProfileDirs {
+		expanded, err := expandPath(dir)
+		if err != nil {
+			return nil, err
+		}
+		expanded = filepath.Clean(expanded)
+		if !seen[expanded] {
+			seen[expanded] = true
+			dirs = append(dirs, expanded)
+		}
+	}
+	return dirs, nil
+}
+
+// writeProfileDir returns the directory new profiles are written to:
+// write_dir when set, otherwise the first entry of profile_dirs.
+func writeProfileDir(cfg *Config) (string, error) {
+	dir := cfg.WriteDir
+	if strings.TrimSpace(dir) == "" {
+		dir = cfg.ProfileDirs[0]
+	}
+	expanded, err := expandPath(dir)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Clean(expanded), nil
+}
+
+func validateProfileName(name string) error {
+	if strings.TrimSpace(name) == "" {
+		return fmt.Errorf("profile name cannot be empty")
+	}
+	if strings.Contains(name, "/") {
+		return fmt.Errorf("profile name cannot contain '/'")
+	}
+	return nil
+}
+
+// findProfile resolves name against the search path, or against the
+// current project for project:<name>.
+func findProfile(name string) (profileLocation, error) {
+	name = strings.TrimSpace(name)
+	if isProjectProfileName(name) {
+		return findProjectProfile(name)
+	}
+	if err := validateProfileName(name); err != nil {
+		return profileLocation{}, err
+	}
+	cfg, err := loadConfig()
+	if err != nil {
+		return profileLocation{}, err
+	}
+	dirs, err := profileSearchPath(cfg)
+	if err != nil {
+		return profileLocation{}, err
+	}
+	for _, dir := range dirs {
+		profilePath := filepath.Join(dir, name+".env")
+		if info, err := os.Stat(profilePath); err == nil && !info.IsDir() {
+			return profileLocation{name: name, path: profilePath, dir: dir}, nil
+		}
+	}
+	return profileLocation{}, fmt.Errorf("profile '%s' does not exist", name)
+}
+
+// ensureInWriteDir refuses changes to a profile that was resolved from a
+// directory other than the writable one, such as a shared team checkout.
+func ensureInWriteDir(loc profileLocation) error {
+	if isProjectProfileName(loc.name) {
+		return nil
+	}
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	writeDir, err := writeProfileDir(cfg)
+	if err != nil {
+		return err
+	}
+	if loc.dir != writeDir {
+		return fmt.Errorf("profile '%s' lives in %s, which is not the writable profile directory (%s)", loc.name, displayPath(loc.dir), displayPath(writeDir))
+	}
+	return nil
+}
+
+// ensureWritable refuses changes to a read-only profile: one listed in
+// readonly_profiles or one that lives outside the writable directory.
+func ensureWritable(loc profileLocation) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	if isReadOnlyProfile(cfg, loc.name) {
+		return fmt.Errorf("profile '%s' is read-only (run envman profile writable %s to allow changes)", loc.name, loc.name)
+	}
+	return ensureInWriteDir(loc)
+}
+
+// displayPath shortens paths under the home directory to ~/...
+func displayPath(path string) string {
+	home, err := homeDir()
+	if err != nil || home == "" {
+		return path
+	}
+	if path == home {
+		return "~"
+	}
+	if strings.HasPrefix(path, home+string(filepath.Separator)) {
+		return "~" + path[len(home):]
+	}
+	return path
+}
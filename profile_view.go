@@ -3,7 +3,6 @@ package main
 import (
 	"fmt"
 	"os"
-	"os/user"
 	"strings"
 	"time"
 
@@ -16,6 +15,7 @@ type ViewConfig struct {
 	profileName string
 	entries     int
 	lastMod     time.Time
+	protected   bool
 }
 
 type Viewer struct {
@@ -55,6 +55,13 @@ func NewViewer(config ViewConfig) *Viewer {
 func (v *Viewer) layout() *tview.Flex {
 	flex := tview.NewFlex().SetDirection(tview.FlexRow)
 
+	if v.config.protected {
+		banner := tview.NewTextView().
+			SetDynamicColors(true).
+			SetText(fmt.Sprintf("[white:red:b] %s PROTECTED PROFILE: %s ", iconWarning, v.config.profileName))
+		flex.AddItem(banner, 1, 0, false)
+	}
+
 	flex.AddItem(v.header, 2, 0, false)
 
 	flex.AddItem(v.textView, 0, 1, true)
@@ -153,33 +160,61 @@ func (v *Viewer) Run() error {
 	return nil
 }
 
-func ViewProfile(name string) error {
-	currentUser, err := user.Current()
+// ViewProfile shows a profile in the viewer, or prints it in format. As
+// printing exposes every value, a protected profile needs the same
+// confirmation as exporting it unless yes is set by --yes-i-mean-prod.
+func ViewProfile(name string, format string, yes bool) error {
+	if err := validateOutputFormat(format); err != nil {
+		return err
+	}
+
+	loc, err := findProfile(name)
 	if err != nil {
-		return fmt.Errorf("failed to get current user: %v", err)
+		return err
 	}
-	configFilePath := fmt.Sprintf("/home/%s/.config/%s/%s", currentUser.Username, ProjectName, configFileName)
-	configContent, err := os.ReadFile(configFilePath)
+	profilePath := loc.path
+	cfg, err := loadConfig()
 	if err != nil {
-		return fmt.Errorf("failed to read config file: %v", err)
+		return err
 	}
-	var profileDir string
-	for _, line := range strings.Split(string(configContent), "\n") {
-		if strings.HasPrefix(line, "PROFILE_DIR=") {
-			profileDir = strings.TrimPrefix(line, "PROFILE_DIR=")
-			profileDir = strings.Split(profileDir, "#")[0]
-			profileDir = strings.TrimSpace(profileDir)
-			break
+	protected := isProtectedProfile(cfg, name)
+
+	if format != "" && format != "table" {
+		fileInfo, err := os.Stat(profilePath)
+		if err != nil {
+			return fmt.Errorf("failed to stat profile: %v", err)
 		}
+		content, err := os.ReadFile(profilePath)
+		if err != nil {
+			return fmt.Errorf("failed to read profile: %v", err)
+		}
+		writeDir, err := writeProfileDir(cfg)
+		if err != nil {
+			return err
+		}
+		record := newProfileRecord(ProfileInfo{
+			name:         name,
+			path:         profilePath,
+			dir:          loc.dir,
+			size:         fileInfo.Size(),
+			lastModified: fileInfo.ModTime(),
+			entries:      getProfileEntries(profilePath),
+			flags:        profileFlags(cfg, name, loc.dir, writeDir, false),
+		})
+		if protected {
+			if err := confirmProtected([]string{name}, "print", yes); err != nil {
+				return err
+			}
+			fmt.Fprintln(os.Stderr, protectedBanner(name))
+		}
+		record.Variables = parseProfileVariables(string(content))
+		return writeProfileDetail(os.Stdout, format, record)
 	}
-	if profileDir == "" {
-		return fmt.Errorf("PROFILE_DIR not found in config")
-	}
-	profilePath := fmt.Sprintf("%s/%s.env", profileDir, name)
 
 	config := ViewConfig{
 		filePath:    profilePath,
 		profileName: name,
+		protected:   protected,
 	}
 
 	viewer := NewViewer(config)
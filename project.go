@@ -0,0 +1,158 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+const (
+	projectProfilePrefix  = "project:"
+	projectConfigFileName = "envman.toml"
+	projectProfileDirName = ".envman"
+)
+
+const projectConfigTemplate = `# envman project configuration
+# Profiles in profile_dir are available as project:<name>. Commit
+# non-secret templates here and keep secrets in your global profiles.
+profile_dir = ".envman"
+
+# Profiles loaded on entering this directory when the shell hook is enabled
+# (envman config set autoload true). Each change needs an envman allow.
+# autoload = ["project:dev"]
+`
+
+// Project is a directory tree with its own profiles, found by looking
+// upward from the working directory.
+type Project struct {
+	root       string
+	profileDir string
+}
+
+type projectConfig struct {
+	ProfileDir string   `toml:"profile_dir"`
+	Autoload   []string `toml:"autoload"`
+}
+
+// findProject looks upward from the working directory for envman.toml or
+// an .envman/ directory. It returns nil when the working directory is not
+// inside a project. Global profile directories named .envman (such as
+// ~/.envman) are not mistaken for projects.
+func findProject() (*Project, error) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get working directory: %v", err)
+	}
+	globalDirs := globalProfileDirs()
+
+	dir := cwd
+	for {
+		configPath := filepath.Join(dir, projectConfigFileName)
+		if info, err := os.Stat(configPath); err == nil && !info.IsDir() {
+			var cfg projectConfig
+			if _, err := toml.DecodeFile(configPath, &cfg); err != nil {
+				return nil, fmt.Errorf("failed to parse %s: %v", configPath, err)
+			}
+			profileDir := cfg.ProfileDir
+			if profileDir == "" {
+				profileDir = projectProfileDirName
+			}
+			if !filepath.IsAbs(profileDir) {
+				profileDir = filepath.Join(dir, profileDir)
+			}
+			return &Project{root: dir, profileDir: filepath.Clean(profileDir)}, nil
+		}
+
+		profileDir := filepath.Join(dir, projectProfileDirName)
+		if info, err := os.Stat(profileDir); err == nil && info.IsDir() && !globalDirs[profileDir] {
+			return &Project{root: dir, profileDir: profileDir}, nil
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil, nil
+		}
+		dir = parent
+	}
+}
+
+func globalProfileDirs() map[string]bool {
+	dirs := make(map[string]bool)
+	if data, err := dataDir(); err == nil {
+		dirs[filepath.Clean(data)] = true
+	}
+	if cfg, err := loadConfig(); err == nil {
+		if searchPath, err := profileSearchPath(cfg); err == nil {
+			for _, dir := range searchPath {
+				dirs[dir] = true
+			}
+		}
+	}
+	return dirs
+}
+
+func isProjectProfileName(name string) bool {
+	return strings.HasPrefix(name, projectProfilePrefix)
+}
+
+// requireProject returns the current project or an error explaining that
+// project: profiles need one.
+func requireProject() (*Project, error) {
+	project, err := findProject()
+	if err != nil {
+		return nil, err
+	}
+	if project == nil {
+		return nil, fmt.Errorf("not inside an envman project (no %s or %s/ found), run: envman init --project", projectConfigFileName, projectProfileDirName)
+	}
+	return project, nil
+}
+
+func findProjectProfile(name string) (profileLocation, error) {
+	base := strings.TrimPrefix(name, projectProfilePrefix)
+	if err := validateProfileName(base); err != nil {
+		return profileLocation{}, err
+	}
+	project, err := requireProject()
+	if err != nil {
+		return profileLocation{}, err
+	}
+	profilePath := filepath.Join(project.profileDir, base+".env")
+	if info, err := os.Stat(profilePath); err != nil || info.IsDir() {
+		return profileLocation{}, fmt.Errorf("profile '%s' does not exist in %s", name, displayPath(project.profileDir))
+	}
+	return profileLocation{name: name, path: profilePath, dir: project.profileDir}, nil
+}
+
+// InitProject makes the working directory an envman project.
+func InitProject() error {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("failed to get working directory: %v", err)
+	}
+
+	profileDir := filepath.Join(cwd, projectProfileDirName)
+	if err := os.MkdirAll(profileDir, profileDirMode); err != nil {
+		return fmt.Errorf("failed to create %s: %v", profileDir, err)
+	}
+
+	configPath := filepath.Join(cwd, projectConfigFileName)
+	if _, err := os.Stat(configPath); os.IsNotExist(err) {
+		if err := os.WriteFile(configPath, []byte(projectConfigTemplate), 0644); err != nil {
+			return fmt.Errorf("failed to create %s: %v", configPath, err)
+		}
+	}
+
+	fmt.Printf("%s%s%s Project initialized:%s %s\n",
+		colorGreen,
+		colorBold,
+		iconCheck,
+		colorReset,
+		cwd,
+	)
+	fmt.Printf("%s Create project profiles with: envman profile create %s<name>\n", iconInfo, projectProfilePrefix)
+	return nil
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+const (
+	// flagAllowProtected lets profile edit open a protected profile.
+	flagAllowProtected = "--allow-protected"
+	// flagYesIMeanProd skips the confirmation for protected profiles, and
+	// is the only way to use them without a terminal.
+	flagYesIMeanProd = "--yes-i-mean-prod"
+)
+
+var configConfirmModes = []string{"yes", "name"}
+
+// extractFlag removes every occurrence of flag from args and reports
+// whether it was present.
+func extractFlag(args []string, flag string) ([]string, bool) {
+	rest := make([]string, 0, len(args))
+	found := false
+	for _, arg := range args {
+		if arg == flag {
+			found = true
+			continue
+		}
+		rest = append(rest, arg)
+	}
+	return rest, found
+}
+
+// protectedProfiles returns the protected profiles among names, with stacks
+// expanded to their members.
+func protectedProfiles(names []string) ([]string, error) {
+	cfg, err := loadConfig()
+	if err != nil {
+		return nil, err
+	}
+	expanded, err := expandStacks(names)
+	if err != nil {
+		return nil, err
+	}
+	var protected []string
+	for _, name := range expanded {
+		if isProtectedProfile(cfg, name) && !containsString(protected, name) {
+			protected = append(protected, name)
+		}
+	}
+	return protected, nil
+}
+
+// confirmProtected asks on the terminal before protected profiles among
+// names are used for action. Depending on protected_confirm the user
+// answers y or types each profile name. Without a terminal it refuses
+// unless skip is set by --yes-i-mean-prod.
+func confirmProtected(names []string, action string, skip bool) error {
+	protected, err := protectedProfiles(names)
+	if err != nil || len(protected) == 0 || skip {
+		return err
+	}
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if info, statErr := os.Stdin.Stat(); err != nil || statErr != nil || info.Mode()&os.ModeCharDevice == 0 {
+		if tty != nil {
+			tty.Close()
+		}
+		return fmt.Errorf("profile '%s' is protected; refusing to %s it without a terminal (pass %s to override)", protected[0], action, flagYesIMeanProd)
+	}
+	defer tty.Close()
+
+	reader := bufio.NewReader(tty)
+	for _, name := range protected {
+		fmt.Fprintf(tty, "%s%s%s '%s' is a protected profile.%s\n",
+			colorRed,
+			colorBold,
+			iconWarning,
+			name,
+			colorReset,
+		)
+		if cfg.ProtectedConfirm == "name" {
+			fmt.Fprintf(tty, "Type the profile name to %s it: ", action)
+		} else {
+			fmt.Fprintf(tty, "Really %s it? [y/N]: ", action)
+		}
+		answer, err := reader.ReadString('\n')
+		answer = strings.TrimSpace(answer)
+		if cfg.ProtectedConfirm == "name" {
+			if err == nil && answer == name {
+				continue
+			}
+		} else if answer = strings.ToLower(answer); err == nil && (answer == "y" || answer == "yes") {
+			continue
+		}
+		return fmt.Errorf("%s of protected profile '%s' cancelled", action, name)
+	}
+	return nil
+}
+
+// protectedBanner is the warning printed above a protected profile.
+func protectedBanner(name string) string {
+	return fmt.Sprintf("%s%s%s PROTECTED PROFILE: %s — changes and loads need confirmation%s",
+		colorRed,
+		colorBold,
+		iconWarning,
+		name,
+		colorReset,
+	)
+}
+
+// SetProtected adds name to or removes it from protected_profiles.
+func SetProtected(name string, protected bool) error {
+	if protected {
+		if _, err := findProfile(name); err != nil {
+			return err
+		}
+	}
+	changed, err := updateProfileList("protected_profiles", name, protected)
+	if err != nil {
+		return err
+	}
+	switch {
+	case !changed && protected:
+		return fmt.Errorf("profile '%s' is already protected", name)
+	case !changed:
+		return fmt.Errorf("profile '%s' is not protected", name)
+	}
+
+	state := "Protected"
+	if !protected {
+		state = "Unprotected"
+	}
+	fmt.Printf("%s%s%s %s:%s %s\n",
+		colorGreen,
+		colorBold,
+		iconCheck,
+		state,
+		colorReset,
+		name,
+	)
+	return nil
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/rivo/tview"
+)
+
+// autosaveInterval is how often the editor writes unsaved changes to the
+// recovery file.
+const autosaveInterval = 5 * time.Second
+
+// recoveryDir holds the editor's autosaved buffers, outside the profile
+// directories so shared ones never see them.
+func recoveryDir() (string, error) {
+	data, err := dataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(data, "recovery"), nil
+}
+
+// recoveryPath returns the recovery file for a profile, named after a hash
+// of its path so profiles with the same name in different directories do
+// not collide.
+func recoveryPath(profilePath string) (string, error) {
+	dir, err := recoveryDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, contentHash(profilePath)[:16]+".env"), nil
+}
+
+// writeRecovery stores the buffer if it has unsaved changes that are not in
+// the recovery file yet.
+func (e *Editor) writeRecovery() {
+	if !e.hasChanges {
+		return
+	}
+	text := e.textArea.GetText()
+	if text == e.recovered {
+		return
+	}
+	path, err := recoveryPath(e.config.filePath)
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(path), profileDirMode); err != nil {
+		return
+	}
+	if err := writeFileAtomic(path, []byte(text), profileFileMode); err == nil {
+		e.recovered = text
+	}
+}
+
+// removeRecovery deletes the recovery file once the buffer is saved or
+// deliberately discarded.
+func (e *Editor) removeRecovery() {
+	if path, err := recoveryPath(e.config.filePath); err == nil {
+		os.Remove(path)
+	}
+	e.recovered = ""
+}
+
+// autosave writes the recovery file every autosaveInterval until the
+// editor stops.
+func (e *Editor) autosave(stop <-chan struct{}) {
+	ticker := time.NewTicker(autosaveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			e.app.QueueUpdate(e.writeRecovery)
+		}
+	}
+}
+
+// offerRecovery checks for a buffer left behind by an editor that did not
+// exit cleanly and lets the user recover, compare or discard it.
+func (e *Editor) offerRecovery(flex *tview.Flex) {
+	path, err := recoveryPath(e.config.filePath)
+	if err != nil {
+		return
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return
+	}
+	content, err := os.ReadFile(path)
+	if err != nil || string(content) == e.textArea.GetText() {
+		os.Remove(path)
+		return
+	}
+	buffer := string(content)
+
+	e.recoveryPending = true
+	restore := func() {
+		e.recoveryPending = false
+		e.textArea.SetText(buffer, false)
+		e.hasChanges = true
+		e.recovered = buffer
+		e.updateStatus()
+		e.app.SetRoot(flex, true)
+		e.messages.SetText("[yellow::b]Recovered unsaved changes. Review and save with Ctrl+S.")
+	}
+	discard := func() {
+		e.recoveryPending = false
+		e.removeRecovery()
+		e.app.SetRoot(flex, true)
+	}
+
+	modal := tview.NewModal().
+		SetText(fmt.Sprintf("Unsaved changes to '%s' from an editor that did not exit cleanly were found (autosaved %s).\n\nRecover them, compare them with the profile, or discard them?",
+			e.config.profileName,
+			info.ModTime().Format("2006-01-02 15:04:05"),
+		)).
+		AddButtons([]string{"Recover", "Compare", "Discard"}).
+		SetDoneFunc(func(buttonIndex int, buttonLabel string) {
+			switch buttonLabel {
+			case "Recover":
+				restore()
+			case "Compare":
+				buttons := tview.NewForm().
+					AddButton("Recover", restore).
+					AddButton("Discard", discard)
+				e.app.SetRoot(compareView(" Profile ", e.textArea.GetText(), " Unsaved changes ", buffer,
+					"Recover replaces the editor's text with the unsaved changes.", buttons), true)
+			case "Discard":
+				discard()
+			default:
+				e.app.SetRoot(flex, true)
+			}
+		})
+	e.app.SetRoot(modal, false)
+}
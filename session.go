@@ -0,0 +1,365 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// envActive lists the profiles loaded into the current shell as
+// comma-separated name@unix-time@content-hash@KEY1:KEY2 entries, oldest
+// first. The key list lets reload unset keys removed from a profile.
+const envActive = "ENVMAN_ACTIVE"
+
+// envRestore holds what the shell had in each key before a load first set
+// it, encoded like ENVMAN_AUTOLOAD_RESTORE, so reload can put back keys
+// removed from a profile instead of unsetting them.
+const envRestore = "ENVMAN_RESTORE"
+
+type activeProfile struct {
+	name     string
+	loadedAt time.Time
+	hash     string
+	keys     []string
+}
+
+func parseActive(value string) []activeProfile {
+	var active []activeProfile
+	for _, entry := range strings.Split(value, ",") {
+		parts := strings.Split(entry, "@")
+		if len(parts) != 3 && len(parts) != 4 {
+			continue
+		}
+		name, err := url.PathUnescape(parts[0])
+		if err != nil || name == "" {
+			continue
+		}
+		unix, err := strconv.ParseInt(parts[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		a := activeProfile{name: name, loadedAt: time.Unix(unix, 0), hash: parts[2]}
+		if len(parts) == 4 && parts[3] != "" {
+			a.keys = strings.Split(parts[3], ":")
+		}
+		active = append(active, a)
+	}
+	return active
+}
+
+func formatActive(active []activeProfile) string {
+	entries := make([]string, len(active))
+	for i, a := range active {
+		entries[i] = fmt.Sprintf("%s@%d@%s@%s", url.PathEscape(a.name), a.loadedAt.Unix(), a.hash, strings.Join(a.keys, ":"))
+	}
+	return strings.Join(entries, ",")
+}
+
+func profileFileHash(path string) (string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read profile: %v", err)
+	}
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])[:12], nil
+}
+
+// activateProfiles records layers as loaded now. A profile that was
+// already active moves to the end, matching the order its values now apply
+// in.
+func activateProfiles(active []activeProfile, layers []profileLayer) ([]activeProfile, error) {
+	now := time.Now()
+	for _, layer := range layers {
+		loc, err := findProfile(layer.name)
+		if err != nil {
+			return nil, err
+		}
+		hash, err := profileFileHash(loc.path)
+		if err != nil {
+			return nil, err
+		}
+		kept := active[:0:0]
+		for _, a := range active {
+			if a.name != layer.name {
+				kept = append(kept, a)
+			}
+		}
+		active = append(kept, activeProfile{name: layer.name, loadedAt: now, hash: hash, keys: layer.keys})
+	}
+	return active, nil
+}
+
+// recordPrevious adds the current value of each key in vars to restore,
+// keeping values already recorded by an earlier load.
+func recordPrevious(restore map[string]previousValue, vars []profileVariable) {
+	for _, v := range vars {
+		if _, done := restore[v.Key]; !done {
+			value, set := os.LookupEnv(v.Key)
+			restore[v.Key] = previousValue{Set: set, Value: value}
+		}
+	}
+}
+
+// sessionVariables returns ENVMAN_ACTIVE with layers added to what the
+// current environment already has loaded, and ENVMAN_RESTORE with the
+// values vars are about to replace.
+func sessionVariables(vars []profileVariable, layers []profileLayer) ([]profileVariable, error) {
+	active, err := activateProfiles(parseActive(os.Getenv(envActive)), layers)
+	if err != nil {
+		return nil, err
+	}
+	restore := decodeRestore(os.Getenv(envRestore))
+	recordPrevious(restore, vars)
+	return []profileVariable{
+		{Key: envActive, Value: formatActive(active)},
+		{Key: envRestore, Value: encodeRestore(restore)},
+	}, nil
+}
+
+// isActiveProfile reports whether name is loaded in the current shell.
+func isActiveProfile(name string) bool {
+	for _, a := range parseActive(os.Getenv(envActive)) {
+		if a.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// profileState compares a loaded profile with its file on disk.
+func profileState(a activeProfile) string {
+	loc, err := findProfile(a.name)
+	if err != nil {
+		return "missing"
+	}
+	hash, err := profileFileHash(loc.path)
+	if err != nil {
+		return "missing"
+	}
+	if hash != a.hash {
+		return "changed since load"
+	}
+	return "unchanged"
+}
+
+func formatAge(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		return fmt.Sprintf("%dm ago", int(d.Minutes()))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh ago", int(d.Hours()))
+	}
+	return fmt.Sprintf("%dd ago", int(d.Hours()/24))
+}
+
+// Status reports the profiles loaded in the current shell.
+func Status() error {
+	active := parseActive(os.Getenv(envActive))
+	if len(active) == 0 {
+		fmt.Printf("%s No profiles loaded in this shell\n", iconInfo)
+		return nil
+	}
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("\n%s %s%sActive Profiles:%s (%d loaded, later ones win)\n\n",
+		iconInfo,
+		colorGreen,
+		colorBold,
+		colorReset,
+		len(active),
+	)
+	fmt.Printf("%s%s%-20s %-29s %s%s\n",
+		colorBold,
+		colorYellow,
+		"Profile Name",
+		"Loaded",
+		"State",
+		colorReset,
+	)
+	fmt.Printf("%s%s%s\n", colorYellow, strings.Repeat("-", 70), colorReset)
+
+	for _, a := range active {
+		name := fmt.Sprintf("%-20s", a.name)
+		state := profileState(a)
+		stateColor := colorGreen
+		if state != "unchanged" {
+			stateColor = colorYellow
+		}
+		if isProtectedProfile(cfg, a.name) {
+			name = colorRed + name + colorReset
+			state += ", protected"
+		}
+		fmt.Printf("%s%s%s %-29s %s%s%s\n",
+			colorBold,
+			name,
+			colorReset,
+			fmt.Sprintf("%s (%s)", a.loadedAt.Format("2006-01-02 15:04"), formatAge(time.Since(a.loadedAt))),
+			stateColor,
+			state,
+			colorReset,
+		)
+	}
+	fmt.Println()
+	return nil
+}
+
+// PromptSegment prints the active profiles for a shell prompt, with
+// protected profiles in red. Color codes are wrapped so the shell does not
+// count them towards the prompt width.
+func PromptSegment(shell string) error {
+	active := parseActive(os.Getenv(envActive))
+	if len(active) == 0 {
+		return nil
+	}
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	wrap := func(code string) string {
+		if code == "" {
+			return ""
+		}
+		switch shell {
+		case "bash":
+			return "\001" + code + "\002"
+		case "zsh":
+			return "%{" + code + "%}"
+		}
+		return code
+	}
+
+	names := make([]string, len(active))
+	for i, a := range active {
+		names[i] = a.name
+		if isProtectedProfile(cfg, a.name) {
+			names[i] = wrap(colorRed+colorBold) + a.name + wrap(colorReset)
+		}
+	}
+	fmt.Printf("[%s] ", strings.Join(names, " "))
+	return nil
+}
+
+// changedProfiles returns the loaded profiles whose files changed or
+// disappeared since they were loaded.
+func changedProfiles() []string {
+	var changed []string
+	for _, a := range parseActive(os.Getenv(envActive)) {
+		if profileState(a) != "unchanged" {
+			changed = append(changed, a.name)
+		}
+	}
+	return changed
+}
+
+// ReloadHint prints a one-line reminder when loaded profiles changed on
+// disk. The prompt hook calls it before every prompt.
+func ReloadHint() {
+	if changed := changedProfiles(); len(changed) > 0 {
+		fmt.Printf("envman: %s changed since loaded, run 'envman reload' to apply\n", strings.Join(changed, ", "))
+	}
+}
+
+// ReloadProfiles prints shell code that re-applies every profile in the
+// session in its original order. Keys a profile no longer defines get back
+// the value they had before the load, or are unset if they had none;
+// profiles that no longer exist are dropped from the session.
+// Protected profiles are confirmed again unless yes is set.
+func ReloadProfiles(shell string, yes bool) error {
+	active := parseActive(os.Getenv(envActive))
+	if len(active) == 0 {
+		return fmt.Errorf("no profiles loaded in this shell")
+	}
+
+	var names []string
+	for _, a := range active {
+		if _, err := findProfile(a.name); err != nil {
+			fmt.Fprintf(os.Stderr, "%s%s%s Dropped %s:%s %v\n",
+				colorYellow,
+				colorBold,
+				iconWarning,
+				a.name,
+				colorReset,
+				err,
+			)
+			continue
+		}
+		names = append(names, a.name)
+	}
+
+	if err := confirmProtected(names, "reload", yes); err != nil {
+		return err
+	}
+
+	var vars []profileVariable
+	var layers []profileLayer
+	if len(names) > 0 {
+		var err error
+		if vars, layers, err = mergeProfiles(names); err != nil {
+			return err
+		}
+	}
+
+	defined := make(map[string]bool)
+	for _, v := range vars {
+		defined[v.Key] = true
+	}
+	restore := decodeRestore(os.Getenv(envRestore))
+	var removed, restored []string
+	var out strings.Builder
+	for _, a := range active {
+		for _, key := range a.keys {
+			if defined[key] || containsString(removed, key) || containsString(restored, key) {
+				continue
+			}
+			if prev := restore[key]; prev.Set {
+				restored = append(restored, key)
+				out.WriteString(formatSet(key, prev.Value, shell))
+			} else {
+				removed = append(removed, key)
+				out.WriteString(formatUnset(key, shell))
+			}
+			delete(restore, key)
+		}
+	}
+	recordPrevious(restore, vars)
+
+	reloaded, err := activateProfiles(nil, layers)
+	if err != nil {
+		return err
+	}
+	out.WriteString(formatExports(vars, shell))
+	if len(reloaded) > 0 {
+		out.WriteString(formatSet(envActive, formatActive(reloaded), shell))
+		out.WriteString(formatSet(envRestore, encodeRestore(restore), shell))
+	} else {
+		out.WriteString(formatUnset(envActive, shell))
+		out.WriteString(formatUnset(envRestore, shell))
+	}
+	fmt.Print(out.String())
+
+	fmt.Fprintf(os.Stderr, "%s%s%s Reloaded:%s %s\n",
+		colorGreen,
+		colorBold,
+		iconCheck,
+		colorReset,
+		strings.Join(names, ", "),
+	)
+	if len(restored) > 0 {
+		fmt.Fprintf(os.Stderr, "  %srestored:%s %s\n", colorYellow, colorReset, strings.Join(restored, ", "))
+	}
+	if len(removed) > 0 {
+		fmt.Fprintf(os.Stderr, "  %sunset:%s %s\n", colorYellow, colorReset, strings.Join(removed, ", "))
+	}
+	return nil
+}
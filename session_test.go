@@ -0,0 +1,57 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReloadRestoresOverriddenKeys(t *testing.T) {
+	dir := testHome(t)
+	t.Setenv(envActive, "")
+	t.Setenv(envRestore, "")
+	t.Setenv("ENVMAN_TEST_PATH", "/usr/bin")
+	os.Unsetenv("ENVMAN_TEST_NEW")
+	profile := filepath.Join(dir, "p.env")
+	if err := os.WriteFile(profile, []byte("ENVMAN_TEST_PATH=/opt/bin\nENVMAN_TEST_NEW=1\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// Load p as the shell would.
+	vars, layers, err := mergeProfiles([]string{"p"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	session, err := sessionVariables(vars, layers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range append(vars, session...) {
+		t.Setenv(v.Key, v.Value)
+	}
+
+	if err := os.WriteFile(profile, []byte("OTHER=1\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout, stderr := os.Stdout, os.Stderr
+	os.Stdout, os.Stderr = w, w
+	err = ReloadProfiles("bash", true)
+	os.Stdout, os.Stderr = stdout, stderr
+	w.Close()
+	out, _ := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{"export ENVMAN_TEST_PATH='/usr/bin'", "unset ENVMAN_TEST_NEW"} {
+		if !strings.Contains(string(out), want+"\n") {
+			t.Errorf("reload output lacks %q:\n%s", want, out)
+		}
+	}
+}
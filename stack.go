@@ -0,0 +1,201 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const stackUsage = `Usage:
+  envman stack create <name> <profile...>  Define a stack (profiles may be joined with +)
+  envman stack list                        Show all stacks
+  envman stack show <name>                 Show a stack's profiles and where they resolve
+  envman stack delete <name>               Remove a stack`
+
+// validateStack checks a stack definition. Members are applied left to
+// right like envman load a b c and must be profiles, not other stacks.
+func validateStack(cfg *Config, name string, members []string) error {
+	if err := validateProfileName(name); err != nil {
+		return fmt.Errorf("invalid stack name '%s': %v", name, err)
+	}
+	if isProjectProfileName(name) {
+		return fmt.Errorf("invalid stack name '%s': stacks cannot use the %s prefix", name, projectProfilePrefix)
+	}
+	if len(members) == 0 {
+		return fmt.Errorf("stack '%s' must list at least one profile", name)
+	}
+	for _, member := range members {
+		if err := validateProfileName(strings.TrimPrefix(member, projectProfilePrefix)); err != nil {
+			return fmt.Errorf("stack '%s': %v", name, err)
+		}
+		if _, ok := cfg.Stacks[member]; ok {
+			return fmt.Errorf("stack '%s' cannot include another stack ('%s')", name, member)
+		}
+	}
+	return nil
+}
+
+// parseStackMembers accepts "base postgres", "base + postgres" and
+// "base+postgres" alike.
+func parseStackMembers(args []string) []string {
+	var members []string
+	for _, arg := range args {
+		for _, m := range strings.Split(arg, "+") {
+			if m = strings.TrimSpace(m); m != "" {
+				members = append(members, m)
+			}
+		}
+	}
+	return members
+}
+
+// expandStacks replaces stack names with their member profiles. Stacks are
+// checked first; a profile cannot be created under a stack's name.
+func expandStacks(names []string) ([]string, error) {
+	cfg, err := loadConfig()
+	if err != nil {
+		return nil, err
+	}
+	var expanded []string
+	for _, name := range names {
+		if members, ok := cfg.Stacks[name]; ok {
+			expanded = append(expanded, members...)
+		} else {
+			expanded = append(expanded, name)
+		}
+	}
+	return expanded, nil
+}
+
+func sortedStackNames(cfg *Config) []string {
+	names := make([]string, 0, len(cfg.Stacks))
+	for name := range cfg.Stacks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func StackCommand(args []string) error {
+	if len(args) == 0 {
+		fmt.Println(stackUsage)
+		return nil
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "create":
+		if len(args) < 3 {
+			return fmt.Errorf("usage: envman stack create <name> <profile...>")
+		}
+		name := args[1]
+		if loc, err := findProfile(name); err == nil {
+			return fmt.Errorf("a profile named '%s' already exists in %s", name, displayPath(loc.dir))
+		}
+		members := parseStackMembers(args[2:])
+		for _, member := range members {
+			if isProjectProfileName(member) {
+				continue
+			}
+			if _, err := findProfile(member); err != nil {
+				return err
+			}
+		}
+		err := updateConfig(func(cfg *Config) (bool, error) {
+			if _, ok := cfg.Stacks[name]; ok {
+				return false, fmt.Errorf("stack '%s' already exists", name)
+			}
+			if err := validateStack(cfg, name, members); err != nil {
+				return false, err
+			}
+			cfg.Stacks[name] = members
+			return true, nil
+		})
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s%s%s Stack created:%s %s = %s\n",
+			colorGreen,
+			colorBold,
+			iconCheck,
+			colorReset,
+			name,
+			strings.Join(members, " + "),
+		)
+		return nil
+	case "list":
+		if len(cfg.Stacks) == 0 {
+			fmt.Printf("%s No stacks defined. Create one with: envman stack create <name> <profile...>\n", iconInfo)
+			return nil
+		}
+		for _, name := range sortedStackNames(cfg) {
+			fmt.Printf("%s%-20s%s %s\n", colorBold, name, colorReset, strings.Join(cfg.Stacks[name], " + "))
+		}
+		return nil
+	case "show":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: envman stack show <name>")
+		}
+		members, ok := cfg.Stacks[args[1]]
+		if !ok {
+			return fmt.Errorf("stack '%s' does not exist", args[1])
+		}
+		fmt.Printf("%s %s%sStack %s:%s %s\n\n",
+			iconInfo,
+			colorGreen,
+			colorBold,
+			args[1],
+			colorReset,
+			strings.Join(members, " + "),
+		)
+		for i, member := range members {
+			source := ""
+			if loc, err := findProfile(member); err != nil {
+				source = fmt.Sprintf("%s%v%s", colorRed, err, colorReset)
+			} else {
+				source = displayPath(loc.path)
+			}
+			fmt.Printf("  %d. %s%-20s%s %s\n", i+1, colorBold, member, colorReset, source)
+		}
+		fmt.Println("\nLater profiles override earlier ones.")
+		return nil
+	case "delete":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: envman stack delete <name>")
+		}
+		err := updateConfig(func(cfg *Config) (bool, error) {
+			if _, ok := cfg.Stacks[args[1]]; !ok {
+				return false, fmt.Errorf("stack '%s' does not exist", args[1])
+			}
+			delete(cfg.Stacks, args[1])
+			return true, nil
+		})
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s%s%s Stack deleted:%s %s\n",
+			colorGreen,
+			colorBold,
+			iconCheck,
+			colorReset,
+			args[1],
+		)
+		return nil
+	}
+	return fmt.Errorf("unknown stack command '%s'\n%s", args[0], stackUsage)
+}
+
+// stackExists reports whether name is taken by a stack, so that new
+// profiles do not hide behind one.
+func stackExists(name string) bool {
+	cfg, err := loadConfig()
+	if err != nil {
+		return false
+	}
+	_, ok := cfg.Stacks[name]
+	return ok
+}